@@ -0,0 +1,373 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/cli"
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+// Kinds of difference reported by compare
+const (
+	kindUnparsable string = "unparsable"
+	kindRcode      string = "rcode"
+	kindRRset      string = "rrset"
+	kindTTL        string = "ttl"
+)
+
+// difference is a name and type at which the server does not serve the records
+// in Netbox
+type difference struct {
+	Zone   string   `json:"zone"`
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Kind   string   `json:"kind"`
+	Netbox []string `json:"netbox,omitempty"`
+	Server []string `json:"server,omitempty"`
+	// Message describes differences that are not in the records, such as the
+	// rcode returned
+	Message string `json:"message,omitempty"`
+}
+
+// rrsetKey identifies an RRset by its lowercase owner name and type
+type rrsetKey struct {
+	name   string
+	rrtype uint16
+}
+
+func newRRsetKey(rr dns.RR) rrsetKey {
+	return rrsetKey{
+		name:   strings.ToLower(dns.Fqdn(rr.Header().Name)),
+		rrtype: rr.Header().Rrtype,
+	}
+}
+
+// rrsets groups resource records by name and type
+type rrsets map[rrsetKey][]dns.RR
+
+func (sets rrsets) add(rr dns.RR) {
+	key := newRRsetKey(rr)
+	sets[key] = append(sets[key], rr)
+}
+
+// sortedKeys returns the keys of sets in canonical name order, then by type
+func (sets rrsets) sortedKeys() []rrsetKey {
+	keys := make([]rrsetKey, 0, len(sets))
+	for key := range sets {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b rrsetKey) int {
+		return cmp.Or(
+			cli.CompareNames(a.name, b.name),
+			cmp.Compare(a.rrtype, b.rrtype),
+		)
+	})
+	return keys
+}
+
+// comparer compares the zones in Netbox against a DNS server
+type comparer struct {
+	backend netbox.Backend
+	server  string
+	client  *dns.Client
+	// transfer compares each zone against a transfer of it instead of
+	// querying each RRset
+	transfer bool
+	view     string
+}
+
+// compare returns the differences between the zones named, or every zone if
+// none are, and the server
+func (c *comparer) compare(ctx context.Context, names []string) ([]difference, error) {
+	zones, err := c.zones(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	var differences []difference
+	for _, zone := range zones {
+		zoneDifferences, err := c.compareZone(ctx, zone)
+		if err != nil {
+			return nil, fmt.Errorf("zone %q: %w", zone.Name, err)
+		}
+		differences = append(differences, zoneDifferences...)
+	}
+	return differences, nil
+}
+
+// zones returns the zones named in the view compared, or every zone in it if
+// none are named. A zone in more than one view must have its view selected.
+func (c *comparer) zones(ctx context.Context, names []string) ([]netbox.Zone, error) {
+	all, err := c.backend.Zones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[netbox.NormalizeName(name)] = true
+	}
+	found := make(map[string]netbox.Zone)
+	for _, zone := range all {
+		name := netbox.NormalizeName(zone.Name)
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		if c.view != "" && zone.View.Name != c.view {
+			continue
+		}
+		if other, ok := found[name]; ok {
+			return nil, fmt.Errorf(
+				"zone %q is in views %q and %q; select one with -view",
+				name,
+				other.View.Name,
+				zone.View.Name,
+			)
+		}
+		found[name] = zone
+	}
+	var missing []string
+	for name := range wanted {
+		if _, ok := found[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return nil, fmt.Errorf("no such zones: %s", strings.Join(missing, ", "))
+	}
+	zones := make([]netbox.Zone, 0, len(found))
+	for _, zone := range found {
+		zones = append(zones, zone)
+	}
+	slices.SortFunc(zones, func(a, b netbox.Zone) int {
+		return cli.CompareNames(dns.Fqdn(a.Name), dns.Fqdn(b.Name))
+	})
+	return zones, nil
+}
+
+func (c *comparer) compareZone(
+	ctx context.Context,
+	zone netbox.Zone,
+) ([]difference, error) {
+	records, err := c.backend.Records(ctx, &netbox.RecordQuery{Zone: &zone})
+	if err != nil {
+		return nil, err
+	}
+	var differences []difference
+	expected := make(rrsets)
+	for _, record := range records {
+		rr, err := netbox.RecordToRR(record)
+		var recordErr *netbox.RecordError
+		switch {
+		case errors.As(err, &recordErr):
+			differences = append(differences, difference{
+				Zone:    zone.Name,
+				Name:    record.FQDN,
+				Type:    record.Type,
+				Kind:    kindUnparsable,
+				Message: recordErr.Err.Error(),
+			})
+		case err != nil:
+			return nil, err
+		case rr != nil:
+			expected.add(rr)
+		}
+	}
+	if c.transfer {
+		actual, err := c.transferZone(zone)
+		if err != nil {
+			return nil, err
+		}
+		return append(differences, diffZone(zone, expected, actual)...), nil
+	}
+	for _, key := range expected.sortedKeys() {
+		actual, rcode, err := c.query(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if rcode != dns.RcodeSuccess {
+			differences = append(differences, difference{
+				Zone:    zone.Name,
+				Name:    key.name,
+				Type:    dns.TypeToString[key.rrtype],
+				Kind:    kindRcode,
+				Netbox:  rrStrings(expected[key]),
+				Message: "server returned " + dns.RcodeToString[rcode],
+			})
+			continue
+		}
+		differences = append(
+			differences,
+			diffRRset(zone, key, expected[key], actual)...,
+		)
+	}
+	return differences, nil
+}
+
+// query returns the RRset of key served by the server. The RRset is taken
+// from the answer, or from the authority and additional sections of a
+// referral for the NS and glue records of a delegation.
+func (c *comparer) query(
+	ctx context.Context,
+	key rrsetKey,
+) ([]dns.RR, int, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(key.name, key.rrtype)
+	msg.RecursionDesired = false
+	msg.SetEdns0(dns.DefaultMsgSize, false)
+	response, _, err := c.client.ExchangeContext(ctx, msg, c.server)
+	if err == nil && response.Truncated {
+		tcpClient := *c.client
+		tcpClient.Net = "tcp"
+		response, _, err = tcpClient.ExchangeContext(ctx, msg, c.server)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf(
+			"could not query [%s] %q: %w",
+			dns.TypeToString[key.rrtype],
+			key.name,
+			err,
+		)
+	}
+	var rrset []dns.RR
+	for _, section := range [][]dns.RR{response.Answer, response.Ns, response.Extra} {
+		for _, rr := range section {
+			if newRRsetKey(rr) == key {
+				rrset = append(rrset, rr)
+			}
+		}
+		if len(rrset) > 0 {
+			break
+		}
+	}
+	return rrset, response.Rcode, nil
+}
+
+// transferZone returns the RRsets of zone transferred from the server
+func (c *comparer) transferZone(zone netbox.Zone) (rrsets, error) {
+	msg := new(dns.Msg)
+	msg.SetAxfr(dns.Fqdn(zone.Name))
+	transfer := &dns.Transfer{
+		DialTimeout:  c.client.Timeout,
+		ReadTimeout:  c.client.Timeout,
+		WriteTimeout: c.client.Timeout,
+	}
+	envelopes, err := transfer.In(msg, c.server)
+	if err != nil {
+		return nil, fmt.Errorf("could not transfer zone: %w", err)
+	}
+	actual := make(rrsets)
+	soas := 0
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return nil, fmt.Errorf("could not transfer zone: %w", envelope.Error)
+		}
+		for _, rr := range envelope.RR {
+			// the SOA record begins and ends the transfer
+			if rr.Header().Rrtype == dns.TypeSOA {
+				soas++
+				if soas > 1 {
+					continue
+				}
+			}
+			actual.add(rr)
+		}
+	}
+	return actual, nil
+}
+
+// diffZone compares every RRset of a zone in Netbox and on the server
+func diffZone(zone netbox.Zone, expected rrsets, actual rrsets) []difference {
+	all := make(rrsets, len(expected))
+	for key := range expected {
+		all[key] = nil
+	}
+	for key := range actual {
+		all[key] = nil
+	}
+	var differences []difference
+	for _, key := range all.sortedKeys() {
+		differences = append(
+			differences,
+			diffRRset(zone, key, expected[key], actual[key])...,
+		)
+	}
+	return differences
+}
+
+// diffRRset compares an RRset in Netbox and on the server, reporting records
+// only on one side, or a difference in TTL if the records are the same
+func diffRRset(
+	zone netbox.Zone,
+	key rrsetKey,
+	expected []dns.RR,
+	actual []dns.RR,
+) []difference {
+	onlyNetbox := subtract(expected, actual)
+	onlyServer := subtract(actual, expected)
+	if len(onlyNetbox) > 0 || len(onlyServer) > 0 {
+		return []difference{{
+			Zone:   zone.Name,
+			Name:   key.name,
+			Type:   dns.TypeToString[key.rrtype],
+			Kind:   kindRRset,
+			Netbox: rrStrings(onlyNetbox),
+			Server: rrStrings(onlyServer),
+		}}
+	}
+	if len(expected) == 0 {
+		return nil
+	}
+	expectedTTL, actualTTL := minTTL(expected), minTTL(actual)
+	if expectedTTL != actualTTL {
+		return []difference{{
+			Zone: zone.Name,
+			Name: key.name,
+			Type: dns.TypeToString[key.rrtype],
+			Kind: kindTTL,
+			Message: fmt.Sprintf(
+				"TTL is %d in netbox and %d on the server",
+				expectedTTL,
+				actualTTL,
+			),
+		}}
+	}
+	return nil
+}
+
+// subtract returns the records of a that are not in b, ignoring TTLs
+func subtract(a []dns.RR, b []dns.RR) []dns.RR {
+	var out []dns.RR
+	for _, rr := range a {
+		if !slices.ContainsFunc(b, func(other dns.RR) bool {
+			return dns.IsDuplicate(rr, other)
+		}) {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
+// minTTL returns the TTL of an RRset, which resolvers take as the lowest TTL
+// of its records
+func minTTL(rrset []dns.RR) uint32 {
+	ttl := rrset[0].Header().Ttl
+	for _, rr := range rrset[1:] {
+		ttl = min(ttl, rr.Header().Ttl)
+	}
+	return ttl
+}
+
+func rrStrings(rrs []dns.RR) []string {
+	out := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		out = append(out, rr.String())
+	}
+	slices.Sort(out)
+	return out
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+// testServerZone differs from .testing/export.json in the TTL of the A record
+// and the value of the AAAA record of dns01, has no www, and has mail
+const testServerZone string = `$ORIGIN example.com.
+@	86400	IN	SOA	dns01.example.com. admin.example.com. 1 43200 7200 2419200 3600
+@	3600	IN	NS	dns01.example.com.
+dns01	600	IN	A	10.0.0.10
+dns01	300	IN	AAAA	2001:db8:dead:beef::1:11
+mail	3600	IN	A	10.0.0.20
+`
+
+// newTestDNSServer starts an authoritative server for testServerZone on
+// network, answering queries and zone transfers, and returns its address
+func newTestDNSServer(t *testing.T, network string) string {
+	t.Helper()
+	var rrs []dns.RR
+	parser := dns.NewZoneParser(strings.NewReader(testServerZone), "", "")
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := parser.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := dns.HandlerFunc(func(writer dns.ResponseWriter, request *dns.Msg) {
+		question := request.Question[0]
+		if question.Qtype == dns.TypeAXFR {
+			envelopes := make(chan *dns.Envelope)
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				new(dns.Transfer).Out(writer, request, envelopes)
+			}()
+			envelopes <- &dns.Envelope{RR: append(rrs, rrs[0])}
+			close(envelopes)
+			wg.Wait()
+			return
+		}
+		response := new(dns.Msg)
+		response.SetReply(request)
+		response.Authoritative = true
+		response.Rcode = dns.RcodeNameError
+		for _, rr := range rrs {
+			if !strings.EqualFold(rr.Header().Name, question.Name) {
+				continue
+			}
+			response.Rcode = dns.RcodeSuccess
+			if rr.Header().Rrtype == question.Qtype {
+				response.Answer = append(response.Answer, rr)
+			}
+		}
+		writer.WriteMsg(response)
+	})
+
+	server := &dns.Server{Handler: handler}
+	started := make(chan struct{})
+	server.NotifyStartedFunc = func() { close(started) }
+	switch network {
+	case "udp":
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.PacketConn = conn
+	case "tcp":
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.Listener = listener
+	}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+	if server.PacketConn != nil {
+		return server.PacketConn.LocalAddr().String()
+	}
+	return server.Listener.Addr().String()
+}
+
+func newTestComparer(t *testing.T, network string, transfer bool) *comparer {
+	t.Helper()
+	backend, err := netbox.NewFileBackend("../../.testing/export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &comparer{
+		backend:  backend,
+		server:   newTestDNSServer(t, network),
+		client:   &dns.Client{Net: network, Timeout: 5 * time.Second},
+		transfer: transfer,
+	}
+}
+
+type wantDifference struct {
+	name string
+	kind string
+}
+
+func checkDifferences(t *testing.T, got []difference, want []wantDifference) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d differences, got %+v", len(want), got)
+	}
+	for k, w := range want {
+		if got[k].Name+" "+got[k].Type != w.name || got[k].Kind != w.kind {
+			t.Errorf(
+				"expected difference %d to be %s at %s, got %+v",
+				k,
+				w.kind,
+				w.name,
+				got[k],
+			)
+		}
+	}
+}
+
+func TestCompareQueries(t *testing.T) {
+	c := newTestComparer(t, "udp", false)
+	differences, err := c.compare(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	checkDifferences(t, differences, []wantDifference{
+		{"dns01.example.com. A", kindTTL},
+		{"dns01.example.com. AAAA", kindRRset},
+		{"www.example.com. CNAME", kindRcode},
+	})
+	if differences[2].Message != "server returned NXDOMAIN" {
+		t.Errorf("expected NXDOMAIN, got %q", differences[2].Message)
+	}
+
+	var out bytes.Buffer
+	if err := writeText(&out, differences); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"dns01.example.com. A: ttl: TTL is 3600 in netbox and 600 on the server",
+		"\t- dns01.example.com.\t300\tIN\tAAAA\t2001:db8:dead:beef::1:10",
+		"\t+ dns01.example.com.\t300\tIN\tAAAA\t2001:db8:dead:beef::1:11",
+		"3 differences",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCompareTransfer(t *testing.T) {
+	c := newTestComparer(t, "tcp", true)
+	differences, err := c.compare(context.Background(), []string{"example.com"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	checkDifferences(t, differences, []wantDifference{
+		{"dns01.example.com. A", kindTTL},
+		{"dns01.example.com. AAAA", kindRRset},
+		{"mail.example.com. A", kindRRset},
+		{"www.example.com. CNAME", kindRRset},
+	})
+	if len(differences[2].Netbox) != 0 || len(differences[2].Server) != 1 {
+		t.Errorf("expected mail to be only on the server, got %+v", differences[2])
+	}
+}
+
+func TestCompareUnknownZone(t *testing.T) {
+	c := newTestComparer(t, "udp", false)
+	_, err := c.compare(context.Background(), []string{"example.net"})
+	if err == nil || !strings.Contains(err.Error(), "example.net") {
+		t.Errorf("expected an error naming the missing zone, got %v", err)
+	}
+}
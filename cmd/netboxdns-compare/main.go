@@ -0,0 +1,153 @@
+// Command netboxdns-compare reports where a DNS server does not serve the same
+// records as Netbox, such as before cutting over from another server to the
+// netboxdns plugin
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/cli"
+	"github.com/miekg/dns"
+)
+
+const commandName string = "netboxdns-compare"
+
+// exit statuses, in addition to 0 when there are no differences
+const (
+	exitDifferences int = 1
+	exitError       int = 2
+)
+
+// report is the JSON output of the command
+type report struct {
+	Differences []difference `json:"differences"`
+}
+
+func main() {
+	flags := flag.NewFlagSet(commandName, flag.ExitOnError)
+	var backendFlags cli.BackendFlags
+	backendFlags.Register(flags)
+	server := flags.String("server", "", "DNS server to compare, as HOST[:PORT]")
+	transfer := flags.Bool(
+		"axfr",
+		false,
+		"compare a zone transfer from the server instead of querying each RRset",
+	)
+	view := flags.String("view", "", "compare only the zones in this view")
+	dnsTimeout := flags.Duration(
+		"dns-timeout",
+		5*time.Second,
+		"timeout of each query or zone transfer",
+	)
+	jsonOutput := flags.Bool("json", false, "write the differences as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(
+			flags.Output(),
+			"usage: %s [flags] -server HOST[:PORT] [ZONES...]\n\n"+
+				"Compares every zone, or only ZONES, in Netbox against the "+
+				"server.\nExits with status 1 if there are differences, and 2 "+
+				"if the zones could not\nbe compared.\n\n",
+			commandName,
+		)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	differences, err := run(
+		context.Background(),
+		&backendFlags,
+		*server,
+		*transfer,
+		*view,
+		*dnsTimeout,
+		flags.Args(),
+	)
+	if err == nil {
+		if *jsonOutput {
+			err = writeJSON(os.Stdout, differences)
+		} else {
+			err = writeText(os.Stdout, differences)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", commandName, err)
+		os.Exit(exitError)
+	}
+	if len(differences) > 0 {
+		os.Exit(exitDifferences)
+	}
+}
+
+func run(
+	ctx context.Context,
+	backendFlags *cli.BackendFlags,
+	server string,
+	transfer bool,
+	view string,
+	dnsTimeout time.Duration,
+	names []string,
+) ([]difference, error) {
+	if server == "" {
+		return nil, errors.New("-server is required")
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	backend, err := backendFlags.Backend(commandName)
+	if err != nil {
+		return nil, err
+	}
+	c := &comparer{
+		backend:  backend,
+		server:   server,
+		client:   &dns.Client{Timeout: dnsTimeout},
+		transfer: transfer,
+		view:     view,
+	}
+	return c.compare(ctx, names)
+}
+
+func writeJSON(writer io.Writer, differences []difference) error {
+	result := report{Differences: differences}
+	if result.Differences == nil {
+		result.Differences = []difference{}
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// writeText writes each difference as a line naming the RRset, followed by
+// the records only in Netbox prefixed with "-" and those only on the server
+// prefixed with "+"
+func writeText(writer io.Writer, differences []difference) error {
+	var text strings.Builder
+	for _, d := range differences {
+		fmt.Fprintf(&text, "%s %s: %s", d.Name, d.Type, d.Kind)
+		if d.Message != "" {
+			fmt.Fprintf(&text, ": %s", d.Message)
+		}
+		text.WriteString("\n")
+		if d.Kind == kindRRset {
+			for _, rr := range d.Netbox {
+				fmt.Fprintf(&text, "\t- %s\n", rr)
+			}
+			for _, rr := range d.Server {
+				fmt.Fprintf(&text, "\t+ %s\n", rr)
+			}
+		}
+	}
+	fmt.Fprintf(&text, "%d differences\n", len(differences))
+	_, err := io.WriteString(writer, text.String())
+	return err
+}
@@ -0,0 +1,115 @@
+// Command netboxdns-export writes the zones in Netbox as RFC 1035 master files,
+// one per zone, containing the records that the netboxdns plugin serves
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/cli"
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+)
+
+const commandName string = "netboxdns-export"
+
+func main() {
+	flags := flag.NewFlagSet(commandName, flag.ExitOnError)
+	var backendFlags cli.BackendFlags
+	backendFlags.Register(flags)
+	out := flags.String("out", ".", "directory to write the zone files to")
+	flags.Usage = func() {
+		fmt.Fprintf(
+			flags.Output(),
+			"usage: %s [flags] [ZONES...]\n\n"+
+				"Writes every zone, or only ZONES, to OUT/VIEW/ZONE.zone.\n\n",
+			commandName,
+		)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	backend, err := backendFlags.Backend(commandName)
+	if err == nil {
+		err = export(context.Background(), backend, *out, flags.Args())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", commandName, err)
+		os.Exit(1)
+	}
+}
+
+// export writes the zones named, or every zone if none are, to files in out
+func export(
+	ctx context.Context,
+	backend netbox.Backend,
+	out string,
+	names []string,
+) error {
+	zones, err := backend.Zones(ctx)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[netbox.NormalizeName(name)] = true
+	}
+	exported := make(map[string]bool, len(names))
+	for _, zone := range zones {
+		name := netbox.NormalizeName(zone.Name)
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		exported[name] = true
+		records, err := backend.Records(ctx, &netbox.RecordQuery{Zone: &zone})
+		if err != nil {
+			return fmt.Errorf("could not get records of zone %q: %w", zone.Name, err)
+		}
+		if err := writeZone(out, zone, records); err != nil {
+			return err
+		}
+	}
+	var missing []string
+	for name := range wanted {
+		if !exported[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("no such zones: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// writeZone writes the zone file of zone into out, in a directory named after
+// its view if it has one
+func writeZone(out string, zone netbox.Zone, records []netbox.Record) error {
+	dir := out
+	if zone.View.Name != "" {
+		dir = filepath.Join(out, fileName(zone.View.Name))
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, fileName(netbox.NormalizeName(zone.Name))+".zone")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeZoneFile(file, zone, records); err != nil {
+		file.Close()
+		return fmt.Errorf("could not write %q: %w", path, err)
+	}
+	return file.Close()
+}
+
+// fileName replaces the path separators in name, which appear in RFC 2317
+// reverse zones such as 0/26.2.0.192.in-addr.arpa
+func fileName(name string) string {
+	return strings.ReplaceAll(name, "/", "_")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netboxtest"
+	"github.com/miekg/dns"
+)
+
+func TestExport(t *testing.T) {
+	_, backend := netboxtest.NewClient(t)
+	out := t.TempDir()
+	if err := export(context.Background(), backend, out, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	path := filepath.Join(out, "coredns testing", "example.com.zone")
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantPrefix := strings.Join([]string{
+		`; zone example.com in view "coredns testing", exported from Netbox`,
+		"$ORIGIN example.com.",
+		"$TTL 3600",
+		"@\t86400\tIN\tSOA\tdns01.example.com. admin.example.com. 1 43200 7200 2419200 3600",
+		"@\t3600\tIN\tNS\tdns01.example.com.",
+		"@\t3600\tIN\tNS\tdns02.example.com.",
+		"@\t3600\tIN\tMX\t10 mail.example.com.",
+	}, "\n")
+	if !strings.HasPrefix(string(first), wantPrefix) {
+		t.Errorf("expected zone file to start with\n%s\ngot\n%s", wantPrefix, first)
+	}
+
+	// the zone file parses, and contains every record of the zone
+	parser := dns.NewZoneParser(bytes.NewReader(first), "", path)
+	rrs := 0
+	for _, ok := parser.Next(); ok; _, ok = parser.Next() {
+		rrs++
+	}
+	if err := parser.Err(); err != nil {
+		t.Fatalf("expected zone file to parse, got %v", err)
+	}
+	if rrs != 30 {
+		t.Errorf("expected 30 records, got %d", rrs)
+	}
+
+	// exporting again produces the same file
+	if err := export(context.Background(), backend, out, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	second, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("expected repeated export to be identical")
+	}
+}
+
+func TestExportUnknownZone(t *testing.T) {
+	_, backend := netboxtest.NewClient(t)
+	err := export(context.Background(), backend, t.TempDir(), []string{"example.net"})
+	if err == nil || !strings.Contains(err.Error(), "example.net") {
+		t.Errorf("expected an error naming the missing zone, got %v", err)
+	}
+}
+
+func TestWriteZoneFileSynthesized(t *testing.T) {
+	ttl := uint32(300)
+	zone := netbox.Zone{
+		Name:        "example.net",
+		DefaultTTL:  3600,
+		NameServers: []netbox.SOAMName{{Name: "ns2.example.net"}, {Name: "ns1.example.net"}},
+		SOARName:    "hostmaster.example.net",
+		SOASerial:   7,
+		SOARefresh:  3600,
+		SOARetry:    600,
+		SOAExpire:   86400,
+		SOAMinimum:  60,
+		SOATTL:      3600,
+	}
+	records := []netbox.Record{
+		{ID: 2, Type: "A", FQDN: "www.example.net.", Value: "10.0.0.1", TTL: &ttl},
+		{ID: 1, Type: "A", FQDN: "bad.example.net.", Value: "not an address", TTL: &ttl},
+		{ID: 3, Type: "A", FQDN: "a.b.example.net.", Value: "10.0.0.2", TTL: &ttl},
+	}
+	var out bytes.Buffer
+	if err := writeZoneFile(&out, zone, records); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := strings.Join([]string{
+		"; zone example.net, exported from Netbox",
+		"$ORIGIN example.net.",
+		"$TTL 3600",
+		"@\t3600\tIN\tSOA\tns2.example.net. hostmaster.example.net. 7 3600 600 86400 60",
+		"@\t3600\tIN\tNS\tns1.example.net.",
+		"@\t3600\tIN\tNS\tns2.example.net.",
+		"a.b\t300\tIN\tA\t10.0.0.2",
+		"www\t300\tIN\tA\t10.0.0.1",
+		"",
+	}, "\n")
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("expected zone file\n%s\ngot\n%s", want, out.String())
+	}
+	if !strings.Contains(out.String(), "; not served: could not parse record 1 [A]") {
+		t.Errorf("expected unparsable record to be listed, got\n%s", out.String())
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/cli"
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+// writeZoneFile writes the records of zone as an RFC 1035 master file. The SOA
+// and apex NS records are synthesized from the zone if Netbox has none, and
+// records are written in canonical order so that exports diff cleanly. Records
+// that cannot be parsed are listed as comments; the plugin answers SERVFAIL for
+// them, or leaves them out of responses with skip_invalid.
+func writeZoneFile(
+	writer io.Writer,
+	zone netbox.Zone,
+	records []netbox.Record,
+) error {
+	origin := dns.Fqdn(zone.Name)
+	rrs := make([]dns.RR, 0, len(records)+1)
+	var invalid []*netbox.RecordError
+	for _, record := range records {
+		rr, err := netbox.RecordToRR(record)
+		var recordErr *netbox.RecordError
+		switch {
+		case errors.As(err, &recordErr):
+			invalid = append(invalid, recordErr)
+		case err != nil:
+			return err
+		case rr != nil:
+			rrs = append(rrs, rr)
+		}
+	}
+
+	if !hasApexRR(rrs, origin, dns.TypeSOA) {
+		soa, err := synthesizeSOA(zone)
+		if err != nil {
+			return err
+		}
+		rrs = append(rrs, soa)
+	}
+	if !hasApexRR(rrs, origin, dns.TypeNS) {
+		for _, nameServer := range zone.NameServers {
+			rrs = append(rrs, &dns.NS{
+				Hdr: dns.RR_Header{
+					Name:   origin,
+					Rrtype: dns.TypeNS,
+					Class:  dns.ClassINET,
+					Ttl:    zone.DefaultTTL,
+				},
+				Ns: dns.Fqdn(nameServer.Name),
+			})
+		}
+	}
+	slices.SortStableFunc(rrs, func(a, b dns.RR) int {
+		return compareRR(a, b, origin)
+	})
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "; zone %s", zone.Name)
+	if zone.View.Name != "" {
+		fmt.Fprintf(&builder, " in view %q", zone.View.Name)
+	}
+	fmt.Fprintf(&builder, ", exported from Netbox\n")
+	fmt.Fprintf(&builder, "$ORIGIN %s\n", origin)
+	fmt.Fprintf(&builder, "$TTL %d\n", zone.DefaultTTL)
+	for _, rr := range rrs {
+		owner := relativeName(rr.Header().Name, origin)
+		fmt.Fprintf(
+			&builder,
+			"%s%s\n",
+			owner,
+			strings.TrimPrefix(rr.String(), rr.Header().Name),
+		)
+	}
+	slices.SortFunc(invalid, func(a, b *netbox.RecordError) int {
+		return cmp.Compare(a.Record.ID, b.Record.ID)
+	})
+	for _, recordErr := range invalid {
+		fmt.Fprintf(&builder, "; not served: %s\n", recordErr.Error())
+	}
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+func hasApexRR(rrs []dns.RR, origin string, rrtype uint16) bool {
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == rrtype &&
+			strings.EqualFold(rr.Header().Name, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// synthesizeSOA builds the SOA record of a zone from its SOA fields, using the
+// first nameserver if the zone has no primary nameserver set
+func synthesizeSOA(zone netbox.Zone) (*dns.SOA, error) {
+	mname := zone.SOAMName.Name
+	if mname == "" && len(zone.NameServers) > 0 {
+		mname = zone.NameServers[0].Name
+	}
+	if mname == "" {
+		return nil, fmt.Errorf(
+			"zone %q has no SOA record and no nameserver to build one from",
+			zone.Name,
+		)
+	}
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(zone.Name),
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    zone.SOATTL,
+		},
+		Ns:      dns.Fqdn(mname),
+		Mbox:    dns.Fqdn(zone.SOARName),
+		Serial:  zone.SOASerial,
+		Refresh: zone.SOARefresh,
+		Retry:   zone.SOARetry,
+		Expire:  zone.SOAExpire,
+		Minttl:  zone.SOAMinimum,
+	}, nil
+}
+
+// compareRR orders the SOA record first, then the apex NS records, then every
+// other record by canonical name order (RFC 4034), type, and data
+func compareRR(a, b dns.RR, origin string) int {
+	rank := func(rr dns.RR) int {
+		switch {
+		case rr.Header().Rrtype == dns.TypeSOA:
+			return 0
+		case rr.Header().Rrtype == dns.TypeNS &&
+			strings.EqualFold(rr.Header().Name, origin):
+			return 1
+		default:
+			return 2
+		}
+	}
+	return cmp.Or(
+		cmp.Compare(rank(a), rank(b)),
+		cli.CompareNames(a.Header().Name, b.Header().Name),
+		cmp.Compare(a.Header().Rrtype, b.Header().Rrtype),
+		strings.Compare(a.String(), b.String()),
+	)
+}
+
+// relativeName returns name relative to origin, "@" for origin itself, or name
+// unchanged if it is not within origin
+func relativeName(name string, origin string) string {
+	if strings.EqualFold(name, origin) {
+		return "@"
+	}
+	suffix := "." + origin
+	if len(name) > len(suffix) &&
+		strings.EqualFold(name[len(name)-len(suffix):], suffix) {
+		return name[:len(name)-len(suffix)]
+	}
+	return name
+}
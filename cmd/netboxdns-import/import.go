@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+// importer creates and updates zones in Netbox to match zone files. Each
+// change is written to out as it is made, or only written in a dry run.
+type importer struct {
+	client *netbox.APIRequestClient
+	view   string
+	dryRun bool
+	prune  bool
+	out    io.Writer
+
+	// views and nameServers are fetched once and extended with the objects
+	// created, or that would be created in a dry run with ID 0
+	views       []netbox.View
+	nameServers []netbox.NameServer
+}
+
+// result counts the changes to a zone
+type result struct {
+	zone    string
+	created int
+	updated int
+	deleted int
+	kept    int
+}
+
+func (imported result) String() string {
+	return fmt.Sprintf(
+		"%s: %d created, %d updated, %d deleted, %d only in netbox",
+		imported.zone,
+		imported.created,
+		imported.updated,
+		imported.deleted,
+		imported.kept,
+	)
+}
+
+// existingRecord is a record in Netbox, and whether a record of the zone file
+// matches it
+type existingRecord struct {
+	record  netbox.Record
+	rr      dns.RR
+	matched bool
+}
+
+func (existing *existingRecord) String() string {
+	if existing.rr != nil {
+		return existing.rr.String()
+	}
+	return fmt.Sprintf(
+		"%s\t%s\t%s",
+		existing.record.FQDN,
+		existing.record.Type,
+		existing.record.Value,
+	)
+}
+
+// importZone makes the zone in Netbox match zone. Records in Netbox that are
+// not in the zone file are deleted only if prune is set.
+func (imp *importer) importZone(ctx context.Context, zone *zoneFile) (result, error) {
+	imported := result{zone: netbox.NormalizeName(zone.name)}
+	for _, skipped := range zone.skipped {
+		fmt.Fprintf(imp.out, "! skipped %s\n", skipped)
+	}
+	view, err := imp.ensureView(ctx)
+	if err != nil {
+		return imported, err
+	}
+	nameServers, err := imp.ensureNameServers(
+		ctx,
+		append(slices.Clone(zone.nameServers), netbox.NormalizeName(zone.soa.Ns)),
+	)
+	if err != nil {
+		return imported, err
+	}
+	request := &netbox.ZoneRequest{
+		Name:          imported.zone,
+		View:          view.ID,
+		DefaultTTL:    zone.defaultTTL,
+		SOAExpire:     zone.soa.Expire,
+		SOAMinimum:    zone.soa.Minttl,
+		SOAMName:      nameServers[netbox.NormalizeName(zone.soa.Ns)],
+		SOARefresh:    zone.soa.Refresh,
+		SOARetry:      zone.soa.Retry,
+		SOARName:      netbox.NormalizeName(zone.soa.Mbox),
+		SOASerial:     zone.soa.Serial,
+		SOASerialAuto: false,
+		SOATTL:        zone.soa.Hdr.Ttl,
+	}
+	for _, nameServer := range zone.nameServers {
+		request.NameServers = append(request.NameServers, nameServers[nameServer])
+	}
+
+	current, err := imp.findZone(ctx, imported.zone, view.Name)
+	if err != nil {
+		return imported, err
+	}
+	if current == nil {
+		imp.change('+', "zone %s in view %q", imported.zone, view.Name)
+		imported.created++
+		if !imp.dryRun {
+			created, err := netbox.CreateZone(ctx, imp.client, request)
+			if err != nil {
+				return imported, fmt.Errorf(
+					"could not create zone %q: %w",
+					imported.zone,
+					err,
+				)
+			}
+			current = &created
+		}
+	} else if differences := diffZone(*current, zone); len(differences) > 0 {
+		for _, difference := range differences {
+			imp.change('~', "zone %s %s", imported.zone, difference)
+		}
+		imported.updated++
+		if !imp.dryRun {
+			updated, err := netbox.UpdateZone(ctx, imp.client, current.ID, request)
+			if err != nil {
+				return imported, fmt.Errorf(
+					"could not update zone %q: %w",
+					imported.zone,
+					err,
+				)
+			}
+			current = &updated
+		}
+	}
+
+	// a zone that would be created in a dry run has no records yet
+	var existing []*existingRecord
+	zoneID := 0
+	if current != nil {
+		zoneID = current.ID
+		existing, err = imp.existingRecords(ctx, *current, zone.defaultTTL)
+		if err != nil {
+			return imported, err
+		}
+	}
+	for _, rr := range zone.records {
+		ttl := recordTTL(rr, zone.defaultTTL)
+		index := slices.IndexFunc(existing, func(candidate *existingRecord) bool {
+			return !candidate.matched && candidate.rr != nil &&
+				dns.IsDuplicate(candidate.rr, rr)
+		})
+		if index < 0 {
+			imp.change('+', "%s", rr)
+			imported.created++
+			if imp.dryRun {
+				continue
+			}
+			_, err := netbox.CreateRecord(ctx, imp.client, &netbox.RecordRequest{
+				Zone:  zoneID,
+				Name:  relativeName(rr.Header().Name, zone.name),
+				Type:  dns.TypeToString[rr.Header().Rrtype],
+				Value: rdata(rr),
+				TTL:   ttl,
+			})
+			if err != nil {
+				return imported, fmt.Errorf("could not create %q: %w", rr, err)
+			}
+			continue
+		}
+		match := existing[index]
+		match.matched = true
+		if match.rr.Header().Ttl == rr.Header().Ttl {
+			continue
+		}
+		imp.change(
+			'~',
+			"%s (TTL %d -> %d)",
+			rr,
+			match.rr.Header().Ttl,
+			rr.Header().Ttl,
+		)
+		imported.updated++
+		if imp.dryRun {
+			continue
+		}
+		_, err := netbox.UpdateRecord(
+			ctx,
+			imp.client,
+			match.record.ID,
+			&netbox.RecordRequest{
+				Zone:  zoneID,
+				Name:  match.record.Name,
+				Type:  match.record.Type,
+				Value: match.record.Value,
+				TTL:   ttl,
+			},
+		)
+		if err != nil {
+			return imported, fmt.Errorf("could not update %q: %w", rr, err)
+		}
+	}
+	for _, record := range existing {
+		if record.matched {
+			continue
+		}
+		if !imp.prune {
+			imported.kept++
+			continue
+		}
+		imp.change('-', "%s", record)
+		imported.deleted++
+		if imp.dryRun {
+			continue
+		}
+		if err := netbox.DeleteRecord(ctx, imp.client, record.record.ID); err != nil {
+			return imported, fmt.Errorf("could not delete %q: %w", record, err)
+		}
+	}
+	return imported, nil
+}
+
+// ensureView returns the view zones are imported into, creating it if it
+// does not exist
+func (imp *importer) ensureView(ctx context.Context) (netbox.View, error) {
+	if imp.views == nil {
+		views, err := netbox.GetViews(ctx, imp.client)
+		if err != nil {
+			return netbox.View{}, fmt.Errorf("could not get views: %w", err)
+		}
+		imp.views = views
+	}
+	for _, view := range imp.views {
+		if view.Name == imp.view {
+			return view, nil
+		}
+	}
+	imp.change('+', "view %q", imp.view)
+	view := netbox.View{Name: imp.view}
+	if !imp.dryRun {
+		var err error
+		view, err = netbox.CreateView(ctx, imp.client, imp.view)
+		if err != nil {
+			return view, fmt.Errorf("could not create view %q: %w", imp.view, err)
+		}
+	}
+	imp.views = append(imp.views, view)
+	return view, nil
+}
+
+// ensureNameServers returns the IDs of the nameservers named, creating those
+// that do not exist
+func (imp *importer) ensureNameServers(
+	ctx context.Context,
+	names []string,
+) (map[string]int, error) {
+	if imp.nameServers == nil {
+		nameServers, err := netbox.GetNameServers(ctx, imp.client)
+		if err != nil {
+			return nil, fmt.Errorf("could not get nameservers: %w", err)
+		}
+		imp.nameServers = nameServers
+	}
+	ids := make(map[string]int, len(names))
+	for _, name := range names {
+		index := slices.IndexFunc(imp.nameServers, func(nameServer netbox.NameServer) bool {
+			return equalNames(nameServer.Name, name)
+		})
+		if index >= 0 {
+			ids[name] = imp.nameServers[index].ID
+			continue
+		}
+		imp.change('+', "nameserver %s", name)
+		nameServer := netbox.NameServer{Name: name}
+		if !imp.dryRun {
+			var err error
+			nameServer, err = netbox.CreateNameServer(ctx, imp.client, name)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"could not create nameserver %q: %w",
+					name,
+					err,
+				)
+			}
+		}
+		imp.nameServers = append(imp.nameServers, nameServer)
+		ids[name] = nameServer.ID
+	}
+	return ids, nil
+}
+
+// findZone returns the zone named name in view, or nil if there is none
+func (imp *importer) findZone(
+	ctx context.Context,
+	name string,
+	view string,
+) (*netbox.Zone, error) {
+	zones, err := netbox.GetZones(ctx, imp.client)
+	if err != nil {
+		return nil, fmt.Errorf("could not get zones: %w", err)
+	}
+	for _, zone := range zones {
+		if equalNames(zone.Name, name) && zone.View.Name == view {
+			return &zone, nil
+		}
+	}
+	return nil, nil
+}
+
+// existingRecords returns the records of zone that are not managed by Netbox.
+// Records without a TTL are given defaultTTL, the default TTL the zone has
+// once imported.
+func (imp *importer) existingRecords(
+	ctx context.Context,
+	zone netbox.Zone,
+	defaultTTL uint32,
+) ([]*existingRecord, error) {
+	zone.DefaultTTL = defaultTTL
+	records, err := netbox.GetRecordsQuery(
+		ctx,
+		imp.client,
+		&netbox.RecordQuery{Zone: &zone},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not get records of zone %q: %w", zone.Name, err)
+	}
+	existing := make([]*existingRecord, 0, len(records))
+	for _, record := range records {
+		if record.Managed {
+			continue
+		}
+		// records that cannot be parsed never match, so they are replaced
+		rr, _ := netbox.RecordToRR(record)
+		existing = append(existing, &existingRecord{record: record, rr: rr})
+	}
+	return existing, nil
+}
+
+func (imp *importer) change(action byte, format string, args ...any) {
+	fmt.Fprintf(imp.out, "%c %s\n", action, fmt.Sprintf(format, args...))
+}
+
+// diffZone describes the fields of current that differ from zone
+func diffZone(current netbox.Zone, zone *zoneFile) []string {
+	var differences []string
+	compare := func(field string, old any, new any) {
+		if old != new {
+			differences = append(
+				differences,
+				fmt.Sprintf("%s: %v -> %v", field, old, new),
+			)
+		}
+	}
+	currentNameServers := make([]string, 0, len(current.NameServers))
+	for _, nameServer := range current.NameServers {
+		currentNameServers = append(
+			currentNameServers,
+			netbox.NormalizeName(nameServer.Name),
+		)
+	}
+	slices.Sort(currentNameServers)
+	compare("default_ttl", current.DefaultTTL, zone.defaultTTL)
+	compare(
+		"nameservers",
+		strings.Join(currentNameServers, ","),
+		strings.Join(zone.nameServers, ","),
+	)
+	compare("soa_ttl", current.SOATTL, zone.soa.Hdr.Ttl)
+	compare(
+		"soa_mname",
+		netbox.NormalizeName(current.SOAMName.Name),
+		netbox.NormalizeName(zone.soa.Ns),
+	)
+	compare(
+		"soa_rname",
+		netbox.NormalizeName(current.SOARName),
+		netbox.NormalizeName(zone.soa.Mbox),
+	)
+	compare("soa_serial", current.SOASerial, zone.soa.Serial)
+	compare("soa_refresh", current.SOARefresh, zone.soa.Refresh)
+	compare("soa_retry", current.SOARetry, zone.soa.Retry)
+	compare("soa_expire", current.SOAExpire, zone.soa.Expire)
+	compare("soa_minimum", current.SOAMinimum, zone.soa.Minttl)
+	return differences
+}
+
+// recordTTL returns the TTL of rr to store in Netbox, or nil if it is the
+// default TTL of the zone
+func recordTTL(rr dns.RR, defaultTTL uint32) *uint32 {
+	ttl := rr.Header().Ttl
+	if ttl == defaultTTL {
+		return nil
+	}
+	return &ttl
+}
@@ -0,0 +1,131 @@
+// Command netboxdns-import creates and updates zones in Netbox from RFC 1035
+// master files, such as the zone files of a BIND server
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/cli"
+)
+
+const (
+	commandName string = "netboxdns-import"
+	// defaultView is the view netbox-plugin-dns creates zones in by default
+	defaultView string = "_default_"
+)
+
+func main() {
+	flags := flag.NewFlagSet(commandName, flag.ExitOnError)
+	var backendFlags cli.BackendFlags
+	backendFlags.RegisterClient(flags)
+	origin := flags.String(
+		"origin",
+		"",
+		"origin of the zone file, if it has no $ORIGIN and is not named ZONE.zone",
+	)
+	view := flags.String("view", defaultView, "view to import the zones into")
+	dryRun := flags.Bool("dry-run", false, "write the changes without making them")
+	prune := flags.Bool(
+		"prune",
+		false,
+		"delete the records in Netbox that are not in the zone file",
+	)
+	flags.Usage = func() {
+		fmt.Fprintf(
+			flags.Output(),
+			"usage: %s [flags] FILES...\n\n"+
+				"Creates or updates a zone in Netbox to match each zone file, "+
+				"writing each\nchange as it is made. Zones already matching "+
+				"their file are left unchanged.\n\n",
+			commandName,
+		)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	err := run(
+		context.Background(),
+		&backendFlags,
+		*origin,
+		*view,
+		*dryRun,
+		*prune,
+		flags.Args(),
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", commandName, err)
+		os.Exit(1)
+	}
+}
+
+func run(
+	ctx context.Context,
+	backendFlags *cli.BackendFlags,
+	origin string,
+	view string,
+	dryRun bool,
+	prune bool,
+	files []string,
+) error {
+	switch {
+	case len(files) == 0:
+		return errors.New("no zone files given")
+	case origin != "" && len(files) > 1:
+		return errors.New("-origin can only be given with one zone file")
+	}
+	client, err := backendFlags.Client(commandName)
+	if err != nil {
+		return err
+	}
+	imp := &importer{
+		client: client,
+		view:   view,
+		dryRun: dryRun,
+		prune:  prune,
+		out:    os.Stdout,
+	}
+	return imp.importFiles(ctx, origin, files)
+}
+
+// importFiles imports each zone file, with the origin given if there is one
+func (imp *importer) importFiles(
+	ctx context.Context,
+	origin string,
+	files []string,
+) error {
+	for _, file := range files {
+		zone, err := readZoneFile(file, origin)
+		if err != nil {
+			return err
+		}
+		imported, err := imp.importZone(ctx, zone)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		fmt.Fprintln(imp.out, imported)
+	}
+	if imp.dryRun {
+		fmt.Fprintln(imp.out, "dry run; no changes were made")
+	}
+	return nil
+}
+
+// readZoneFile parses the zone file at path. Without an origin, names are
+// relative to the file name without its .zone extension.
+func readZoneFile(path string, origin string) (*zoneFile, error) {
+	if origin == "" {
+		origin = strings.TrimSuffix(filepath.Base(path), ".zone")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseZoneFile(file, origin, path)
+}
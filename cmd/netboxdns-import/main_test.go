@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netboxtest"
+)
+
+const testZoneFile string = `$ORIGIN example.net.
+$TTL 3600
+@	7200	IN	SOA	ns1 hostmaster 2024010101 3600 600 604800 300
+@		IN	NS	ns1
+@		IN	NS	ns2.example.net.
+@		IN	MX	10 mail
+ns1		IN	A	192.0.2.1
+ns2		IN	A	192.0.2.2
+mail		IN	A	192.0.2.20
+www	300	IN	A	192.0.2.10
+www		IN	AAAA	2001:db8::10
+txt		IN	TXT	"hello world" "second"
+sub		IN	NS	ns.sub
+ns.sub		IN	A	192.0.2.30
+other.example.org.	IN	A	192.0.2.40
+`
+
+func writeZoneFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "example.net.zone")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// importFile imports the zone file at path and returns the changes written
+func importFile(
+	t *testing.T,
+	client *netbox.APIRequestClient,
+	path string,
+	dryRun bool,
+	prune bool,
+) string {
+	t.Helper()
+	var out bytes.Buffer
+	imp := &importer{
+		client: client,
+		view:   defaultView,
+		dryRun: dryRun,
+		prune:  prune,
+		out:    &out,
+	}
+	if err := imp.importFiles(context.Background(), "", []string{path}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return out.String()
+}
+
+func TestImport(t *testing.T) {
+	_, client := netboxtest.NewClient(t)
+	path := writeZoneFile(t, testZoneFile)
+
+	out := importFile(t, client, path, true, false)
+	for _, want := range []string{
+		`+ view "_default_"`,
+		"+ nameserver ns1.example.net",
+		`+ zone example.net in view "_default_"`,
+		"+ www.example.net.\t300\tIN\tA\t192.0.2.10",
+		"example.net: 10 created, 0 updated, 0 deleted, 0 only in netbox",
+		"dry run; no changes were made",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected dry run output to contain %q, got\n%s", want, out)
+		}
+	}
+	zones, err := netbox.GetZones(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, zone := range zones {
+		if zone.Name == "example.net" {
+			t.Fatal("expected dry run not to create the zone")
+		}
+	}
+
+	out = importFile(t, client, path, false, false)
+	if !strings.Contains(out, "! skipped outside the zone: other.example.org.") {
+		t.Errorf("expected record outside the zone to be skipped, got\n%s", out)
+	}
+	zones, err = netbox.GetZones(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var imported *netbox.Zone
+	for _, zone := range zones {
+		if zone.Name == "example.net" {
+			imported = &zone
+		}
+	}
+	if imported == nil {
+		t.Fatal("expected zone to be created")
+	}
+	if imported.DefaultTTL != 3600 || imported.SOASerial != 2024010101 ||
+		imported.SOATTL != 7200 || len(imported.NameServers) != 2 {
+		t.Errorf("expected zone fields from the SOA record, got %+v", imported)
+	}
+	records, err := netbox.GetRecordsQuery(
+		context.Background(),
+		client,
+		&netbox.RecordQuery{FQDN: []string{"www.example.net"}, Zone: imported},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %+v", records)
+	}
+	for _, record := range records {
+		if record.Type == "A" && *record.TTL != 300 {
+			t.Errorf("expected TTL 300, got %d", *record.TTL)
+		}
+	}
+
+	// importing again changes nothing
+	out = importFile(t, client, path, false, false)
+	want := "example.net: 0 created, 0 updated, 0 deleted, 0 only in netbox"
+	if !strings.Contains(out, want) {
+		t.Errorf("expected repeated import to change nothing, got\n%s", out)
+	}
+}
+
+func TestImportUpdate(t *testing.T) {
+	_, client := netboxtest.NewClient(t)
+	importFile(t, client, writeZoneFile(t, testZoneFile), false, false)
+
+	changed := strings.NewReplacer(
+		"2024010101", "2024010102",
+		"www	300", "www	600",
+		"txt		IN	TXT	\"hello world\" \"second\"\n", "",
+	).Replace(testZoneFile)
+	path := writeZoneFile(t, changed)
+
+	out := importFile(t, client, path, false, false)
+	for _, want := range []string{
+		"~ zone example.net soa_serial: 2024010101 -> 2024010102",
+		"~ www.example.net.\t600\tIN\tA\t192.0.2.10 (TTL 300 -> 600)",
+		"example.net: 0 created, 2 updated, 0 deleted, 1 only in netbox",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got\n%s", want, out)
+		}
+	}
+
+	out = importFile(t, client, path, false, true)
+	for _, want := range []string{
+		"- txt.example.net.\t3600\tIN\tTXT\t\"hello world\" \"second\"",
+		"example.net: 0 created, 0 updated, 1 deleted, 0 only in netbox",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got\n%s", want, out)
+		}
+	}
+}
+
+func TestParseZoneFile(t *testing.T) {
+	zone, err := parseZoneFile(
+		strings.NewReader(testZoneFile),
+		"example.net",
+		"test",
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if zone.name != "example.net." {
+		t.Errorf("expected zone example.net., got %q", zone.name)
+	}
+	if strings.Join(zone.nameServers, " ") != "ns1.example.net ns2.example.net" {
+		t.Errorf("expected apex NS records as nameservers, got %v", zone.nameServers)
+	}
+	if zone.defaultTTL != 3600 {
+		t.Errorf("expected default TTL 3600, got %d", zone.defaultTTL)
+	}
+	if len(zone.records) != 9 || len(zone.skipped) != 1 {
+		t.Errorf(
+			"expected 9 records and 1 skipped, got %d and %v",
+			len(zone.records),
+			zone.skipped,
+		)
+	}
+	if got := relativeName("NS.Sub.Example.NET.", "example.net."); got != "NS.Sub" {
+		t.Errorf("expected relative name NS.Sub, got %q", got)
+	}
+
+	_, err = parseZoneFile(
+		strings.NewReader("www IN A 192.0.2.1\n"),
+		"example.net",
+		"test",
+	)
+	if err == nil {
+		t.Error("expected error for zone file without an SOA record")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+// zoneFile is a zone parsed from an RFC 1035 master file, split into what
+// Netbox holds as fields of the zone and what it holds as records
+type zoneFile struct {
+	name        string
+	soa         *dns.SOA
+	nameServers []string
+	defaultTTL  uint32
+	records     []dns.RR
+	// skipped describes the records that are not imported
+	skipped []string
+}
+
+// parseZoneFile reads the zone origin from reader. If the file has an SOA
+// record, the zone is named after its owner instead.
+func parseZoneFile(reader io.Reader, origin string, file string) (*zoneFile, error) {
+	parser := dns.NewZoneParser(reader, dns.Fqdn(origin), file)
+	var rrs []dns.RR
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := parser.Err(); err != nil {
+		return nil, err
+	}
+
+	zone := &zoneFile{name: dns.Fqdn(origin)}
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok {
+			if zone.soa != nil {
+				return nil, fmt.Errorf("%s: more than one SOA record", file)
+			}
+			zone.soa = soa
+			zone.name = soa.Hdr.Name
+		}
+	}
+	if zone.soa == nil {
+		return nil, fmt.Errorf("%s: no SOA record", file)
+	}
+
+	for _, rr := range rrs {
+		header := rr.Header()
+		switch {
+		case header.Rrtype == dns.TypeSOA:
+		case !dns.IsSubDomain(zone.name, header.Name):
+			zone.skipped = append(zone.skipped, "outside the zone: "+rr.String())
+		case header.Rrtype == dns.TypeNS && equalNames(header.Name, zone.name):
+			nameServer := netbox.NormalizeName(rr.(*dns.NS).Ns)
+			if !slices.Contains(zone.nameServers, nameServer) {
+				zone.nameServers = append(zone.nameServers, nameServer)
+			}
+		case isSigningType(header.Rrtype):
+			zone.skipped = append(zone.skipped, "signing record: "+rr.String())
+		case slices.ContainsFunc(zone.records, func(other dns.RR) bool {
+			return dns.IsDuplicate(rr, other)
+		}):
+		default:
+			zone.records = append(zone.records, rr)
+		}
+	}
+	slices.Sort(zone.nameServers)
+	zone.defaultTTL = commonTTL(zone.records, zone.soa.Minttl)
+	return zone, nil
+}
+
+// isSigningType returns true for the types of records created by signing a
+// zone, which Netbox does not serve
+func isSigningType(rrtype uint16) bool {
+	switch rrtype {
+	case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3, dns.TypeNSEC3PARAM:
+		return true
+	default:
+		return false
+	}
+}
+
+// commonTTL returns the most common TTL of rrs, the lowest if there is a tie,
+// as the default TTL of the zone, so that as few records as possible need
+// their own TTL. If there are no records, fallback is returned.
+func commonTTL(rrs []dns.RR, fallback uint32) uint32 {
+	counts := make(map[uint32]int)
+	for _, rr := range rrs {
+		counts[rr.Header().Ttl]++
+	}
+	ttl, count := fallback, 0
+	for candidate, candidateCount := range counts {
+		if candidateCount > count || candidateCount == count && candidate < ttl {
+			ttl, count = candidate, candidateCount
+		}
+	}
+	return ttl
+}
+
+// relativeName returns the name of a record as it is stored in Netbox: "@" for
+// the zone apex, or the owner without the zone
+func relativeName(owner string, zone string) string {
+	if equalNames(owner, zone) {
+		return "@"
+	}
+	owner = dns.Fqdn(owner)
+	return owner[:len(owner)-len(dns.Fqdn(zone))-1]
+}
+
+// rdata returns the value of rr as it is stored in Netbox
+func rdata(rr dns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+func equalNames(a string, b string) bool {
+	return netbox.NormalizeName(a) == netbox.NormalizeName(b)
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+// maxTTL is the TTL above which a record is reported, as resolvers commonly
+// cap TTLs at a week
+const maxTTL uint32 = 7 * 24 * 60 * 60
+
+type severity int
+
+const (
+	severityWarning severity = iota
+	severityError
+)
+
+func (s severity) String() string {
+	switch s {
+	case severityWarning:
+		return "warning"
+	case severityError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+func (s severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "warning":
+		*s = severityWarning
+	case "error":
+		*s = severityError
+	default:
+		return fmt.Errorf("unknown severity %q; expected warning or error", text)
+	}
+	return nil
+}
+
+// Checks reported by lint
+const (
+	checkUnparsable    string = "unparsable"
+	checkCNAMEConflict string = "cname-conflict"
+	checkDangling      string = "dangling-target"
+	checkTargetCNAME   string = "target-is-cname"
+	checkMissingGlue   string = "missing-glue"
+	checkTTL           string = "ttl"
+)
+
+// finding is a problem with a record, or with the records at a name
+type finding struct {
+	Check    string   `json:"check"`
+	Severity severity `json:"severity"`
+	View     string   `json:"view,omitempty"`
+	Zone     string   `json:"zone"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type,omitempty"`
+	RecordID int      `json:"record_id,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// entry is a record with the zone it belongs to
+type entry struct {
+	record netbox.Record
+	zone   netbox.Zone
+	rr     dns.RR
+}
+
+// view holds the records of the zones in one Netbox view, by lowercase name
+type view struct {
+	name    string
+	zones   map[string]netbox.Zone
+	records map[string][]entry
+}
+
+// lint fetches every zone and record from backend and reports the records that
+// cannot be served or are likely to be misconfigured
+func lint(ctx context.Context, backend netbox.Backend) ([]finding, error) {
+	zones, err := backend.Zones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	views := make(map[string]*view)
+	var findings []finding
+	for _, zone := range zones {
+		current, ok := views[zone.View.Name]
+		if !ok {
+			current = &view{
+				name:    zone.View.Name,
+				zones:   make(map[string]netbox.Zone),
+				records: make(map[string][]entry),
+			}
+			views[zone.View.Name] = current
+		}
+		current.zones[netbox.NormalizeName(zone.Name)] = zone
+		records, err := backend.Records(ctx, &netbox.RecordQuery{Zone: &zone})
+		if err != nil {
+			return nil, fmt.Errorf("could not get records of zone %q: %w", zone.Name, err)
+		}
+		for _, record := range records {
+			rr, err := netbox.RecordToRR(record)
+			var recordErr *netbox.RecordError
+			switch {
+			case errors.As(err, &recordErr):
+				findings = append(findings, newFinding(
+					checkUnparsable,
+					severityError,
+					entry{record: record, zone: zone},
+					recordErr.Err.Error(),
+				))
+				continue
+			case err != nil:
+				return nil, err
+			case rr == nil:
+				continue
+			}
+			name := netbox.NormalizeName(record.FQDN)
+			current.records[name] = append(
+				current.records[name],
+				entry{record: record, zone: zone, rr: rr},
+			)
+		}
+	}
+	for _, current := range views {
+		findings = append(findings, current.lint()...)
+	}
+	slices.SortFunc(findings, func(a, b finding) int {
+		return cmp.Or(
+			strings.Compare(a.View, b.View),
+			strings.Compare(a.Zone, b.Zone),
+			strings.Compare(a.Name, b.Name),
+			strings.Compare(a.Check, b.Check),
+			cmp.Compare(a.RecordID, b.RecordID),
+		)
+	})
+	return findings, nil
+}
+
+func (current *view) lint() []finding {
+	var findings []finding
+	for _, entries := range current.records {
+		findings = append(findings, lintCNAME(entries)...)
+		findings = append(findings, lintTTL(entries)...)
+		for _, e := range entries {
+			findings = append(findings, current.lintTarget(e)...)
+		}
+	}
+	return findings
+}
+
+// lintCNAME reports a CNAME record at a name that has any other record, which
+// resolvers will not answer consistently (RFC 1034 section 3.6.2)
+func lintCNAME(entries []entry) []finding {
+	var cnames, others []entry
+	for _, e := range entries {
+		if e.rr.Header().Rrtype == dns.TypeCNAME {
+			cnames = append(cnames, e)
+		} else {
+			others = append(others, e)
+		}
+	}
+	var findings []finding
+	if len(cnames) > 1 {
+		for _, e := range cnames {
+			findings = append(findings, newFinding(
+				checkCNAMEConflict,
+				severityError,
+				e,
+				fmt.Sprintf("name has %d CNAME records", len(cnames)),
+			))
+		}
+	}
+	if len(cnames) > 0 && len(others) > 0 {
+		types := make([]string, 0, len(others))
+		for _, e := range others {
+			if !slices.Contains(types, e.record.Type) {
+				types = append(types, e.record.Type)
+			}
+		}
+		slices.Sort(types)
+		for _, e := range cnames {
+			findings = append(findings, newFinding(
+				checkCNAMEConflict,
+				severityError,
+				e,
+				"name also has "+strings.Join(types, ", ")+" records",
+			))
+		}
+	}
+	return findings
+}
+
+// lintTTL reports TTLs of zero or over a week, and records of one RRset with
+// different TTLs (RFC 2181 section 5.2)
+func lintTTL(entries []entry) []finding {
+	var findings []finding
+	ttls := make(map[uint16]uint32)
+	for _, e := range entries {
+		ttl := e.rr.Header().Ttl
+		switch {
+		case ttl == 0:
+			findings = append(findings, newFinding(
+				checkTTL,
+				severityWarning,
+				e,
+				"TTL is 0, so the record is never cached",
+			))
+		case ttl > maxTTL:
+			findings = append(findings, newFinding(
+				checkTTL,
+				severityWarning,
+				e,
+				fmt.Sprintf("TTL %d is longer than a week", ttl),
+			))
+		}
+		rrtype := e.rr.Header().Rrtype
+		if first, ok := ttls[rrtype]; !ok {
+			ttls[rrtype] = ttl
+		} else if first != ttl {
+			findings = append(findings, newFinding(
+				checkTTL,
+				severityWarning,
+				e,
+				fmt.Sprintf(
+					"TTL %d differs from TTL %d of other %s records at the name",
+					ttl,
+					first,
+					e.record.Type,
+				),
+			))
+		}
+	}
+	return findings
+}
+
+// lintTarget reports CNAME, MX, and SRV records whose target is in a zone in
+// Netbox, outside any delegation, but does not exist, and NS records whose
+// in-bailiwick nameserver has no address records
+func (current *view) lintTarget(e entry) []finding {
+	var target string
+	switch rr := e.rr.(type) {
+	case *dns.CNAME:
+		target = rr.Target
+	case *dns.MX:
+		target = rr.Mx
+	case *dns.SRV:
+		target = rr.Target
+	case *dns.NS:
+		return current.lintGlue(e, rr.Ns)
+	default:
+		return nil
+	}
+	target = netbox.NormalizeName(target)
+	// "." is a null MX or SRV record, meaning there is no service
+	if target == "" {
+		return nil
+	}
+	zone := current.zoneOf(target)
+	if zone == nil || current.delegated(target, zone) {
+		return nil
+	}
+	targetEntries := current.records[target]
+	if e.rr.Header().Rrtype == dns.TypeCNAME {
+		if len(targetEntries) == 0 {
+			return []finding{newFinding(
+				checkDangling,
+				severityError,
+				e,
+				fmt.Sprintf("target %q has no records", target),
+			)}
+		}
+		return nil
+	}
+	if hasType(targetEntries, dns.TypeCNAME) {
+		return []finding{newFinding(
+			checkTargetCNAME,
+			severityWarning,
+			e,
+			fmt.Sprintf("target %q is a CNAME (RFC 2181 section 10.3)", target),
+		)}
+	}
+	if !hasType(targetEntries, dns.TypeA) && !hasType(targetEntries, dns.TypeAAAA) {
+		return []finding{newFinding(
+			checkDangling,
+			severityError,
+			e,
+			fmt.Sprintf("target %q has no A or AAAA records", target),
+		)}
+	}
+	return nil
+}
+
+// lintGlue reports a nameserver within the name it serves that has no address
+// records, so resolvers cannot reach it
+func (current *view) lintGlue(e entry, nameServer string) []finding {
+	nameServer = netbox.NormalizeName(nameServer)
+	owner := netbox.NormalizeName(e.record.FQDN)
+	if !dns.IsSubDomain(owner, nameServer) {
+		return nil
+	}
+	entries := current.records[nameServer]
+	if hasType(entries, dns.TypeA) || hasType(entries, dns.TypeAAAA) {
+		return nil
+	}
+	return []finding{newFinding(
+		checkMissingGlue,
+		severityError,
+		e,
+		fmt.Sprintf("nameserver %q has no A or AAAA records", nameServer),
+	)}
+}
+
+// delegated reports whether name is at or below a name in zone that has NS
+// records, so that its records are served by the nameservers delegated to
+// rather than by Netbox
+func (current *view) delegated(name string, zone *netbox.Zone) bool {
+	apex := netbox.NormalizeName(zone.Name)
+	for _, offset := range dns.Split(name) {
+		ancestor := name[offset:]
+		if ancestor == apex {
+			return false
+		}
+		if hasType(current.records[ancestor], dns.TypeNS) {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneOf returns the closest zone in the view that name is in, or nil if there
+// is none
+func (current *view) zoneOf(name string) *netbox.Zone {
+	for _, offset := range dns.Split(name) {
+		if zone, ok := current.zones[name[offset:]]; ok {
+			return &zone
+		}
+	}
+	return nil
+}
+
+func hasType(entries []entry, rrtype uint16) bool {
+	for _, e := range entries {
+		if e.rr.Header().Rrtype == rrtype {
+			return true
+		}
+	}
+	return false
+}
+
+func newFinding(check string, level severity, e entry, message string) finding {
+	return finding{
+		Check:    check,
+		Severity: level,
+		View:     e.zone.View.Name,
+		Zone:     e.zone.Name,
+		Name:     e.record.FQDN,
+		Type:     e.record.Type,
+		RecordID: e.record.ID,
+		Message:  message,
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netboxtest"
+)
+
+func newFileBackend(t *testing.T, export netbox.Export) netbox.Backend {
+	t.Helper()
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := netbox.NewFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestLintFixtures(t *testing.T) {
+	_, backend := netboxtest.NewClient(t)
+	findings, err := lint(context.Background(), backend)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("expected no findings, got %+v", findings)
+	}
+}
+
+func TestLint(t *testing.T) {
+	ttl := func(ttl uint32) *uint32 { return &ttl }
+	zone := netbox.Zone{Name: "example.net", DefaultTTL: 3600}
+	record := func(id int, name string, rrtype string, value string) netbox.Record {
+		return netbox.Record{
+			ID:    id,
+			Zone:  netbox.Zone{Name: zone.Name},
+			Name:  name,
+			Type:  rrtype,
+			Value: value,
+		}
+	}
+	zero := record(9, "cache", "A", "10.0.0.9")
+	zero.TTL = ttl(0)
+	long := record(10, "cache", "A", "10.0.0.10")
+	long.TTL = ttl(30 * 24 * 60 * 60)
+	backend := newFileBackend(t, netbox.Export{
+		Zones: []netbox.Zone{zone},
+		Records: []netbox.Record{
+			record(1, "bad", "A", "not an address"),
+			record(2, "www", "CNAME", "web.example.net."),
+			record(3, "www", "TXT", `"conflict"`),
+			record(4, "alias", "CNAME", "missing.example.net."),
+			record(5, "@", "MX", "10 mail.example.net."),
+			record(6, "mail", "CNAME", "www.example.net."),
+			record(7, "sub", "NS", "ns.sub.example.net."),
+			record(8, "external", "CNAME", "www.example.org."),
+			zero,
+			long,
+			record(11, "@", "MX", "0 ."),
+		},
+	})
+	findings, err := lint(context.Background(), backend)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := []struct {
+		check    string
+		recordID int
+	}{
+		{checkDangling, 4},
+		{checkUnparsable, 1},
+		{checkTTL, 9},
+		{checkTTL, 10},
+		{checkTTL, 10},
+		{checkTargetCNAME, 5},
+		{checkMissingGlue, 7},
+		{checkCNAMEConflict, 2},
+		{checkDangling, 2},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("expected %d findings, got %+v", len(want), findings)
+	}
+	for k, w := range want {
+		if findings[k].Check != w.check || findings[k].RecordID != w.recordID {
+			t.Errorf(
+				"expected finding %d to be %s on record %d, got %+v",
+				k,
+				w.check,
+				w.recordID,
+				findings[k],
+			)
+		}
+	}
+}
+
+func TestLintDelegated(t *testing.T) {
+	zone := netbox.Zone{Name: "example.net", DefaultTTL: 3600}
+	record := func(id int, name string, rrtype string, value string) netbox.Record {
+		return netbox.Record{
+			ID:    id,
+			Zone:  netbox.Zone{Name: zone.Name},
+			Name:  name,
+			Type:  rrtype,
+			Value: value,
+		}
+	}
+	backend := newFileBackend(t, netbox.Export{
+		Zones: []netbox.Zone{zone},
+		Records: []netbox.Record{
+			record(1, "sub", "NS", "ns.example.net."),
+			record(2, "ns", "A", "192.0.2.53"),
+			record(3, "www", "CNAME", "web.sub.example.net."),
+			record(4, "@", "MX", "10 mail.deep.sub.example.net."),
+			record(5, "_sip._udp", "SRV", "10 5 5060 sub.example.net."),
+			record(6, "alias", "CNAME", "missing.example.net."),
+		},
+	})
+	findings, err := lint(context.Background(), backend)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(findings) != 1 || findings[0].RecordID != 6 {
+		t.Errorf(
+			"expected only the dangling target outside the delegation, got %+v",
+			findings,
+		)
+	}
+}
+
+func TestReport(t *testing.T) {
+	result := newReport([]finding{
+		{
+			Check:    checkTTL,
+			Severity: severityWarning,
+			Zone:     "example.net",
+			Name:     "a.example.net.",
+			Message:  "TTL is 0, so the record is never cached",
+		},
+	})
+	if result.fails(severityError) {
+		t.Error("expected a warning not to fail at error")
+	}
+	if !result.fails(severityWarning) {
+		t.Error("expected a warning to fail at warning")
+	}
+
+	var out bytes.Buffer
+	if err := writeJSON(&out, result); err != nil {
+		t.Fatal(err)
+	}
+	var decoded report
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("expected JSON output, got %v", err)
+	}
+	if decoded.Warnings != 1 || decoded.Findings[0].Severity != severityWarning {
+		t.Errorf("expected the finding to round trip, got %+v", decoded)
+	}
+
+	out.Reset()
+	if err := writeJSON(&out, newReport(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"findings": []`) {
+		t.Errorf("expected an empty findings list, got %s", out.String())
+	}
+}
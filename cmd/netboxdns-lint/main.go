@@ -0,0 +1,149 @@
+// Command netboxdns-lint reports the records in Netbox that the netboxdns plugin
+// cannot serve, and those that are likely to be misconfigured
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/cli"
+)
+
+const commandName string = "netboxdns-lint"
+
+// exit statuses, in addition to 0 when no findings are at or above -fail-on
+const (
+	exitFindings int = 1
+	exitError    int = 2
+)
+
+// report is the JSON output of the command
+type report struct {
+	Findings []finding `json:"findings"`
+	Errors   int       `json:"errors"`
+	Warnings int       `json:"warnings"`
+}
+
+func main() {
+	flags := flag.NewFlagSet(commandName, flag.ExitOnError)
+	var backendFlags cli.BackendFlags
+	backendFlags.Register(flags)
+	jsonOutput := flags.Bool("json", false, "write the findings as JSON")
+	failOn := severityError
+	flags.TextVar(
+		&failOn,
+		"fail-on",
+		failOn,
+		"exit with status 1 if there is a finding of this severity or higher, "+
+			"warning or error",
+	)
+	flags.Usage = func() {
+		fmt.Fprintf(
+			flags.Output(),
+			"usage: %s [flags]\n\n"+
+				"Reports records that cannot be served or are likely to be "+
+				"misconfigured.\nExits with status 1 if there are findings at "+
+				"or above -fail-on, and 2 if\nthe records could not be read.\n\n",
+			commandName,
+		)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	backend, err := backendFlags.Backend(commandName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", commandName, err)
+		os.Exit(exitError)
+	}
+	findings, err := lint(context.Background(), backend)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", commandName, err)
+		os.Exit(exitError)
+	}
+	result := newReport(findings)
+	if *jsonOutput {
+		err = writeJSON(os.Stdout, result)
+	} else {
+		err = writeText(os.Stdout, result)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", commandName, err)
+		os.Exit(exitError)
+	}
+	if result.fails(failOn) {
+		os.Exit(exitFindings)
+	}
+}
+
+func newReport(findings []finding) report {
+	result := report{Findings: findings}
+	if result.Findings == nil {
+		result.Findings = []finding{}
+	}
+	for _, f := range findings {
+		switch f.Severity {
+		case severityError:
+			result.Errors++
+		case severityWarning:
+			result.Warnings++
+		}
+	}
+	return result
+}
+
+// fails returns true if a finding is of severity level or higher
+func (result report) fails(level severity) bool {
+	for _, f := range result.Findings {
+		if f.Severity >= level {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(writer io.Writer, result report) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+func writeText(writer io.Writer, result report) error {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	for _, f := range result.Findings {
+		zone := f.Zone
+		if f.View != "" {
+			zone = f.View + "/" + f.Zone
+		}
+		id := ""
+		if f.RecordID != 0 {
+			id = fmt.Sprintf("#%d", f.RecordID)
+		}
+		fmt.Fprintf(
+			table,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			f.Severity,
+			f.Check,
+			zone,
+			f.Name,
+			f.Type,
+			id,
+			f.Message,
+		)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(
+		writer,
+		"%d errors, %d warnings\n",
+		result.Errors,
+		result.Warnings,
+	)
+	return err
+}
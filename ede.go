@@ -0,0 +1,106 @@
+package netboxdns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+// extendedError classifies a lookup error into an RFC 8914 Extended DNS Error
+// so that resolvers can log why a name failed to resolve
+func extendedError(err error) *dns.EDNS0_EDE {
+	var (
+		responseErr *netbox.ResponseError
+		recordErr   *netbox.RecordError
+		netErr      net.Error
+	)
+	switch {
+	case errors.As(err, &responseErr):
+		switch responseErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return &dns.EDNS0_EDE{
+				InfoCode: dns.ExtendedErrorCodeNotAuthoritative,
+				ExtraText: fmt.Sprintf(
+					"netbox api denied access [%d]",
+					responseErr.StatusCode,
+				),
+			}
+		default:
+			return &dns.EDNS0_EDE{
+				InfoCode: dns.ExtendedErrorCodeNetworkError,
+				ExtraText: fmt.Sprintf(
+					"netbox api returned status [%d]",
+					responseErr.StatusCode,
+				),
+			}
+		}
+	case errors.Is(err, netbox.ErrCircuitOpen):
+		return &dns.EDNS0_EDE{
+			InfoCode:  dns.ExtendedErrorCodeNoReachableAuthority,
+			ExtraText: "netbox api is unavailable; failing fast",
+		}
+	case errors.As(err, &recordErr):
+		return &dns.EDNS0_EDE{
+			InfoCode: dns.ExtendedErrorCodeInvalidData,
+			ExtraText: fmt.Sprintf(
+				"netbox record %d has an invalid value",
+				recordErr.Record.ID,
+			),
+		}
+	case errors.Is(err, netbox.ErrDecode):
+		return &dns.EDNS0_EDE{
+			InfoCode:  dns.ExtendedErrorCodeInvalidData,
+			ExtraText: "netbox api returned a malformed response",
+		}
+	case errors.As(err, &netErr):
+		extraText := "could not connect to netbox api"
+		if netErr.Timeout() {
+			extraText = "netbox api request timed out"
+		}
+		return &dns.EDNS0_EDE{
+			InfoCode:  dns.ExtendedErrorCodeNetworkError,
+			ExtraText: extraText,
+		}
+	default:
+		return &dns.EDNS0_EDE{
+			InfoCode:  dns.ExtendedErrorCodeOther,
+			ExtraText: "netbox lookup failed",
+		}
+	}
+}
+
+// maxSkippedRecordIDs is the number of skipped records listed in an Extended
+// DNS Error, to keep it from taking up the response
+const maxSkippedRecordIDs int = 5
+
+// skippedRecordsError describes the invalid records left out of a response
+func skippedRecordsError(skipped skippedRecords) *dns.EDNS0_EDE {
+	if len(skipped) == 1 {
+		return &dns.EDNS0_EDE{
+			InfoCode: dns.ExtendedErrorCodeInvalidData,
+			ExtraText: fmt.Sprintf(
+				"netbox record %d has an invalid value and was skipped",
+				skipped[0],
+			),
+		}
+	}
+	listed := min(len(skipped), maxSkippedRecordIDs)
+	ids := make([]string, 0, listed)
+	for _, id := range skipped[:listed] {
+		ids = append(ids, strconv.Itoa(id))
+	}
+	extraText := "netbox records " + strings.Join(ids, ", ")
+	if len(skipped) > listed {
+		extraText += fmt.Sprintf(" and %d more", len(skipped)-listed)
+	}
+	return &dns.EDNS0_EDE{
+		InfoCode:  dns.ExtendedErrorCodeInvalidData,
+		ExtraText: extraText + " have invalid values and were skipped",
+	}
+}
@@ -0,0 +1,119 @@
+// Package cli holds what is shared by the netboxdns commands
+package cli
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+)
+
+// TokenEnv is the environment variable the token is read from if no token flag
+// is given
+const TokenEnv string = "NETBOX_TOKEN"
+
+// BackendFlags select the Netbox instance, or the JSON export of one, that a
+// command reads zones and records from
+type BackendFlags struct {
+	URL       string
+	Token     string
+	TokenFile string
+	File      string
+	Timeout   time.Duration
+}
+
+// Register adds the backend flags to flags
+func (backendFlags *BackendFlags) Register(flags *flag.FlagSet) {
+	backendFlags.RegisterClient(flags)
+	flags.StringVar(
+		&backendFlags.File,
+		"file",
+		"",
+		"JSON export of the Netbox API to read instead of a Netbox instance",
+	)
+}
+
+// RegisterClient adds the flags that select a Netbox instance to flags, for
+// commands that cannot read from an export
+func (backendFlags *BackendFlags) RegisterClient(flags *flag.FlagSet) {
+	flags.StringVar(
+		&backendFlags.URL,
+		"url",
+		"",
+		"URL of the Netbox instance",
+	)
+	flags.StringVar(
+		&backendFlags.Token,
+		"token",
+		"",
+		"Netbox API token (default $"+TokenEnv+")",
+	)
+	flags.StringVar(
+		&backendFlags.TokenFile,
+		"token-file",
+		"",
+		"file to read the Netbox API token from",
+	)
+	flags.DurationVar(
+		&backendFlags.Timeout,
+		"timeout",
+		30*time.Second,
+		"timeout of each request to Netbox",
+	)
+}
+
+// Backend returns the backend selected by the flags
+func (backendFlags *BackendFlags) Backend(userAgent string) (netbox.Backend, error) {
+	if backendFlags.File != "" {
+		if backendFlags.URL != "" {
+			return nil, errors.New("-file cannot be combined with -url")
+		}
+		return netbox.NewFileBackend(backendFlags.File)
+	}
+	return backendFlags.Client(userAgent)
+}
+
+// Client returns a client for the Netbox instance selected by the flags
+func (backendFlags *BackendFlags) Client(
+	userAgent string,
+) (*netbox.APIRequestClient, error) {
+	if backendFlags.URL == "" {
+		return nil, errors.New("-url is required")
+	}
+	netboxUrl, err := url.Parse(backendFlags.URL)
+	if err != nil {
+		return nil, err
+	}
+	if netboxUrl.Host == "" {
+		return nil, errors.New("-url must be an absolute URL")
+	}
+	requestClient := &netbox.APIRequestClient{
+		Client: &http.Client{
+			Timeout: backendFlags.Timeout,
+		},
+		NetboxURL: netboxUrl.JoinPath("api", "plugins", "netbox-dns"),
+		UserAgent: userAgent,
+	}
+	switch {
+	case backendFlags.TokenFile != "":
+		fileToken, err := netbox.NewFileToken(backendFlags.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		requestClient.TokenSource = fileToken
+	case backendFlags.Token != "":
+		requestClient.Token = backendFlags.Token
+	default:
+		requestClient.Token = os.Getenv(TokenEnv)
+	}
+	if requestClient.Token == "" && requestClient.TokenSource == nil {
+		return nil, errors.New(
+			"a token is required; set -token, -token-file, or $" + TokenEnv,
+		)
+	}
+	return requestClient, nil
+}
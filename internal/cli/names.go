@@ -0,0 +1,18 @@
+package cli
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// CompareNames compares names in canonical order (RFC 4034 section 6.1): label
+// by label from the root, ignoring case
+func CompareNames(a string, b string) int {
+	aLabels := dns.SplitDomainName(strings.ToLower(a))
+	bLabels := dns.SplitDomainName(strings.ToLower(b))
+	slices.Reverse(aLabels)
+	slices.Reverse(bLabels)
+	return slices.Compare(aLabels, bLabels)
+}
@@ -1,21 +1,67 @@
 package netbox
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/opentracing/opentracing-go/ext"
 )
 
 type APIRequestClient struct {
 	Client    *http.Client
 	NetboxURL *url.URL
 	Token     string
-	UserAgent string
+	// TokenSource, if set, provides the token instead of Token
+	TokenSource TokenSource
+	TokenScheme TokenScheme
+	UserAgent   string
+	Retry       RetryPolicy
+	Breaker     *CircuitBreaker
+	// Endpoints, if there is more than one, are the URLs that requests fail
+	// over between. URLs are built from NetboxURL, which must be the URL of
+	// one of the endpoints.
+	Endpoints []*Endpoint
+
+	lastContact atomic.Int64
+	inflight    coalescer
+}
+
+func (requestClient *APIRequestClient) token() string {
+	if requestClient.TokenSource != nil {
+		return requestClient.TokenSource.Token()
+	}
+	return requestClient.Token
+}
+
+// metricsURL identifies the client in metrics by its URL, which is the first
+// URL it is configured with if it fails over between several
+func (requestClient *APIRequestClient) metricsURL() string {
+	if requestClient.NetboxURL == nil {
+		return ""
+	}
+	return requestClient.NetboxURL.String()
+}
+
+// LastContact returns the time of the last request that the Netbox API
+// answered successfully, or the zero time if it never has
+func (requestClient *APIRequestClient) LastContact() time.Time {
+	lastContact := requestClient.lastContact.Load()
+	if lastContact == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, lastContact)
 }
 
 type APIResultModel interface {
-	Record | Zone
+	NameServer | Record | Status | View | Zone
 }
 
 type APIManyResponse[T APIResultModel] struct {
@@ -25,42 +71,169 @@ type APIManyResponse[T APIResultModel] struct {
 	Results  []T    `json:"results"`
 }
 
+// ErrDecode is returned when a Netbox API response body could not be decoded
+var ErrDecode = errors.New("could not unmarshal response")
+
+// ResponseError is returned when the Netbox API responds with a status other
+// than 200 OK, or the status expected of a write
+type ResponseError struct {
+	StatusCode int
+	Status     string
+	// Detail is the body of a failed write or a forbidden token check, which
+	// explains why Netbox rejected it
+	Detail string
+}
+
+func (responseError *ResponseError) Error() string {
+	if responseError.Detail != "" {
+		return fmt.Sprintf(
+			"request error [%d] %q: %s",
+			responseError.StatusCode,
+			responseError.Status,
+			responseError.Detail,
+		)
+	}
+	return fmt.Sprintf(
+		"request error [%d] %q",
+		responseError.StatusCode,
+		responseError.Status,
+	)
+}
+
 func doGet(
+	ctx context.Context,
 	requestClient *APIRequestClient,
+	endpoint string,
 	url string,
 ) (*http.Response, error) {
-	request, err := http.NewRequest("GET", url, nil)
-	if err != nil {
+	span, _ := StartSpan(ctx, "netbox.get "+endpoint)
+	defer span.Finish()
+	ext.HTTPMethod.Set(span, http.MethodGet)
+	ext.HTTPUrl.Set(span, url)
+
+	if err := requestClient.Breaker.allow(); err != nil {
+		ext.LogError(span, err)
 		return nil, err
 	}
 
-	request.Header.Set(
-		"Authorization",
-		fmt.Sprintf("Token %s", requestClient.Token),
+	var (
+		response *http.Response
+		err      error
 	)
+	for attempt := 0; ; attempt++ {
+		response, err = doGetFailover(ctx, requestClient, endpoint, url)
+		retry, retryAfter := retryable(ctx, response, err)
+		if !retry || attempt >= requestClient.Retry.Attempts {
+			break
+		}
+		discardResponse(response)
+		requestRetries.WithLabelValues(endpoint).Inc()
+		span.SetTag("retries", attempt+1)
+		select {
+		case <-ctx.Done():
+			requestClient.Breaker.abandon()
+			ext.LogError(span, ctx.Err())
+			return nil, ctx.Err()
+		case <-time.After(requestClient.Retry.delay(attempt, retryAfter)):
+		}
+	}
+
+	if err != nil && ctx.Err() != nil {
+		requestClient.Breaker.abandon()
+	} else {
+		requestClient.Breaker.record(
+			unavailable(response, err),
+			requestClient.metricsURL(),
+		)
+	}
+	if err == nil {
+		ext.HTTPStatusCode.Set(span, uint16(response.StatusCode))
+	} else {
+		ext.LogError(span, err)
+	}
+	return response, err
+}
+
+func doGetAttempt(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	endpoint string,
+	url string,
+) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", requestClient.authorization())
 
 	request.Header.Set("User-Agent", requestClient.UserAgent)
 
-	return requestClient.Client.Do(request)
+	start := time.Now()
+	response, err := requestClient.Client.Do(request)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(response.StatusCode)
+	}
+	if err == nil && response.StatusCode == http.StatusOK {
+		now := time.Now()
+		requestClient.lastContact.Store(now.UnixNano())
+		lastSuccess.WithLabelValues(requestClient.metricsURL()).Set(
+			float64(now.Unix()),
+		)
+	}
+	requestCount.WithLabelValues(endpoint, code).Inc()
+	requestDuration.WithLabelValues(endpoint, code).Observe(
+		time.Since(start).Seconds(),
+	)
+	return response, err
+}
+
+// discardResponse drains and closes the body of a response that will not be
+// read, so that its connection can be reused
+func discardResponse(response *http.Response) {
+	if response == nil {
+		return
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
 }
 
 func responseError(response *http.Response) error {
 	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf(
-			"request error [%d] %q",
-			response.StatusCode,
-			response.Status,
-		)
+		return &ResponseError{
+			StatusCode: response.StatusCode,
+			Status:     response.Status,
+		}
 	}
 	return nil
 }
 
 func get[T APIResultModel](
+	ctx context.Context,
 	requestClient *APIRequestClient,
+	endpoint string,
+	url string,
+) (T, error) {
+	return coalesce(
+		ctx,
+		requestClient,
+		endpoint,
+		url,
+		func(ctx context.Context) (T, error) {
+			return fetch[T](ctx, requestClient, endpoint, url)
+		},
+	)
+}
+
+func fetch[T APIResultModel](
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	endpoint string,
 	url string,
 ) (T, error) {
 	var out T
-	response, err := doGet(requestClient, url)
+	response, err := doGet(ctx, requestClient, endpoint, url)
 	if err != nil {
 		return out, err
 	}
@@ -70,20 +243,52 @@ func get[T APIResultModel](
 	}
 	decoder := json.NewDecoder(response.Body)
 	if err := decoder.Decode(&out); err != nil {
-		return out, fmt.Errorf("could not unmarshal response: %w", err)
+		return out, fmt.Errorf("%w: %w", ErrDecode, err)
 	}
 	return out, nil
 }
 
 func getMany[T APIResultModel](
+	ctx context.Context,
 	requestClient *APIRequestClient,
+	endpoint string,
+	url string,
+) ([]T, error) {
+	shared, err := coalesce(
+		ctx,
+		requestClient,
+		endpoint,
+		url,
+		func(ctx context.Context) ([]T, error) {
+			return fetchMany[T](ctx, requestClient, endpoint, url)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	// callers modify the returned records, so each receives its own copy
+	out := make([]T, len(shared))
+	copy(out, shared)
+	return out, nil
+}
+
+func fetchMany[T APIResultModel](
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	endpoint string,
 	url string,
 ) ([]T, error) {
 	nextUrl := url
 	var out []T
 
+	pages := 0
+	defer func() {
+		pagesFetched.WithLabelValues(endpoint).Observe(float64(pages))
+	}()
+
 	for nextUrl != "" {
-		response, err := doGet(requestClient, nextUrl)
+		pages++
+		response, err := doGet(ctx, requestClient, endpoint, nextUrl)
 		if err != nil {
 			return out, err
 		}
@@ -96,7 +301,7 @@ func getMany[T APIResultModel](
 		var apiResponse APIManyResponse[T]
 		decoder := json.NewDecoder(response.Body)
 		if err := decoder.Decode(&apiResponse); err != nil {
-			return out, fmt.Errorf("could not unmarshal response: %w", err)
+			return out, fmt.Errorf("%w: %w", ErrDecode, err)
 		}
 
 		if out == nil {
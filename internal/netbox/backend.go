@@ -0,0 +1,30 @@
+package netbox
+
+import (
+	"context"
+	"time"
+)
+
+// Backend provides the zones and records served by the plugin
+type Backend interface {
+	// Zones returns every zone
+	Zones(ctx context.Context) ([]Zone, error)
+	// Records returns the records matching query, with the TTL of records
+	// without one set to the default TTL of their zone
+	Records(ctx context.Context, query *RecordQuery) ([]Record, error)
+	// LastContact returns the time the backend last returned data successfully
+	LastContact() time.Time
+}
+
+// Zones implements the Backend interface
+func (requestClient *APIRequestClient) Zones(ctx context.Context) ([]Zone, error) {
+	return GetZones(ctx, requestClient)
+}
+
+// Records implements the Backend interface
+func (requestClient *APIRequestClient) Records(
+	ctx context.Context,
+	query *RecordQuery,
+) ([]Record, error) {
+	return GetRecordsQuery(ctx, requestClient, query)
+}
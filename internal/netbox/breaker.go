@@ -0,0 +1,97 @@
+package netbox
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const DefaultBreakerCooldown time.Duration = time.Second * 30
+
+// ErrCircuitOpen is returned without contacting Netbox while the circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("netbox api circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota
+	breakerOpen                  // failing fast
+	breakerHalfOpen              // probing
+)
+
+// CircuitBreaker stops requests to the Netbox API after Threshold consecutive
+// failures. Once Cooldown has passed, a single probe request is let through;
+// if it succeeds the breaker closes, otherwise it stays open for another
+// Cooldown. A nil CircuitBreaker never opens.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mutex    sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+// allow returns ErrCircuitOpen if a request must not be made
+func (breaker *CircuitBreaker) allow() error {
+	if breaker == nil {
+		return nil
+	}
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	switch breaker.state {
+	case breakerOpen:
+		cooldown := breaker.Cooldown
+		if cooldown <= 0 {
+			cooldown = DefaultBreakerCooldown
+		}
+		if time.Since(breaker.openedAt) < cooldown {
+			return ErrCircuitOpen
+		}
+		breaker.state = breakerHalfOpen
+		return nil
+	case breakerHalfOpen:
+		// a probe is already in flight
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of a request it allowed. label
+// identifies the client the breaker belongs to in metrics.
+func (breaker *CircuitBreaker) record(failed bool, label string) {
+	if breaker == nil {
+		return
+	}
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	if !failed {
+		breaker.failures = 0
+		breaker.state = breakerClosed
+		breakerOpenGauge.WithLabelValues(label).Set(0)
+		return
+	}
+	breaker.failures++
+	if breaker.state == breakerHalfOpen ||
+		breaker.failures >= breaker.Threshold {
+		breaker.state = breakerOpen
+		breaker.openedAt = time.Now()
+		breakerOpenGauge.WithLabelValues(label).Set(1)
+	}
+}
+
+// abandon is called instead of record when an allowed request was canceled by
+// its caller, so that its outcome says nothing about the Netbox API
+func (breaker *CircuitBreaker) abandon() {
+	if breaker == nil {
+		return
+	}
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	if breaker.state == breakerHalfOpen {
+		// let the next request probe again
+		breaker.state = breakerOpen
+	}
+}
@@ -0,0 +1,85 @@
+package netbox
+
+import (
+	"context"
+	"sync"
+)
+
+// coalescedCall is a request in flight whose result is shared by every caller
+// that asked for the same URL while it was running
+type coalescedCall struct {
+	done  chan struct{}
+	value any
+	err   error
+
+	// waiters is the number of callers still waiting for the result, guarded
+	// by the coalescer mutex
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// coalescer collapses concurrent identical requests into a single request.
+// The zero value is ready to use.
+type coalescer struct {
+	mutex sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalesce runs fetch once for all concurrent callers with the same key. Each
+// caller stops waiting when its own context is done, and the shared request is
+// canceled once every caller has stopped waiting. The request thus runs until
+// the latest deadline of its callers, or until it completes if any caller has
+// no deadline.
+func coalesce[T any](
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	endpoint string,
+	key string,
+	fetch func(context.Context) (T, error),
+) (T, error) {
+	group := &requestClient.inflight
+	group.mutex.Lock()
+	if group.calls == nil {
+		group.calls = make(map[string]*coalescedCall)
+	}
+	call, ok := group.calls[key]
+	if !ok {
+		shared, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+		group.calls[key] = call
+		go func() {
+			call.value, call.err = fetch(shared)
+			group.mutex.Lock()
+			if group.calls[key] == call {
+				delete(group.calls, key)
+			}
+			group.mutex.Unlock()
+			call.cancel()
+			close(call.done)
+		}()
+	} else {
+		requestsCoalesced.WithLabelValues(endpoint).Inc()
+	}
+	call.waiters++
+	group.mutex.Unlock()
+
+	var out T
+	select {
+	case <-ctx.Done():
+		group.mutex.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// nobody is left to receive the result; later callers start a
+			// new request rather than join the canceled one
+			if group.calls[key] == call {
+				delete(group.calls, key)
+			}
+			call.cancel()
+		}
+		group.mutex.Unlock()
+		return out, ctx.Err()
+	case <-call.done:
+	}
+	out, _ = call.value.(T)
+	return out, call.err
+}
@@ -0,0 +1,191 @@
+package netbox
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCoalesce(t *testing.T) {
+	requestClient := &APIRequestClient{}
+	endpoint := "test-coalesce"
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	fetch := func(context.Context) ([]int, error) {
+		fetches.Add(1)
+		<-release
+		return []int{1, 2, 3}, nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make([][]int, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = coalesce(
+				context.Background(),
+				requestClient,
+				endpoint,
+				"key",
+				fetch,
+			)
+		}()
+	}
+
+	// wait for every caller but the first to join the request in flight
+	coalesced := requestsCoalesced.WithLabelValues(endpoint)
+	deadline := time.Now().Add(time.Second * 5)
+	for testutil.ToFloat64(coalesced) < callers-1 {
+		if time.Now().After(deadline) {
+			t.Fatalf(
+				"expected %d coalesced requests, got %v",
+				callers-1,
+				testutil.ToFloat64(coalesced),
+			)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if fetches.Load() != 1 {
+		t.Errorf("expected 1 fetch, got %d", fetches.Load())
+	}
+	for i, result := range results {
+		if len(result) != 3 {
+			t.Errorf("caller %d: expected 3 results, got %d", i, len(result))
+		}
+	}
+}
+
+func TestCoalesceCanceled(t *testing.T) {
+	requestClient := &APIRequestClient{}
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := coalesce(
+		ctx,
+		requestClient,
+		"test-coalesce-canceled",
+		"key",
+		func(context.Context) (int, error) {
+			<-release
+			return 1, nil
+		},
+	)
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestCoalesceAbandoned(t *testing.T) {
+	requestClient := &APIRequestClient{}
+	endpoint := "test-coalesce-abandoned"
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	fetch := func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return 0, ctx.Err()
+	}
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := coalesce(first, requestClient, endpoint, "key", fetch)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err := coalesce(second, requestClient, endpoint, "key", fetch)
+		errs <- err
+	}()
+	coalesced := requestsCoalesced.WithLabelValues(endpoint)
+	deadline := time.Now().Add(time.Second * 5)
+	for testutil.ToFloat64(coalesced) < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("second caller did not join the request in flight")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the request must outlive the caller that started it
+	cancelFirst()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	select {
+	case <-canceled:
+		t.Fatal("request canceled while a caller was still waiting")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	cancelSecond()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second * 5):
+		t.Fatal("request not canceled after every caller gave up")
+	}
+}
+
+func TestCoalesceDeadline(t *testing.T) {
+	requestClient := &APIRequestClient{}
+	endpoint := "test-coalesce-deadline"
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (int, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-release:
+			return 1, nil
+		}
+	}
+
+	results := make(chan error, 1)
+	go func() {
+		_, err := coalesce(
+			context.Background(),
+			requestClient,
+			endpoint,
+			"key",
+			fetch,
+		)
+		results <- err
+	}()
+	<-started
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		time.Millisecond*50,
+	)
+	defer cancel()
+	_, err := coalesce(ctx, requestClient, endpoint, "key", fetch)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	// the waiter without a deadline still receives the result once the waiter
+	// with a deadline has given up
+	close(release)
+	select {
+	case err := <-results:
+		if err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("waiter without a deadline did not receive the result")
+	}
+}
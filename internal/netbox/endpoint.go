@@ -0,0 +1,200 @@
+package netbox
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// endpointRecheckInterval is how long an endpoint that failed is preferred
+	// less than the healthy endpoints before it is tried again as healthy
+	endpointRecheckInterval time.Duration = time.Second * 30
+	// endpointLatencyWeight is the weight of each new sample in the moving
+	// average of an endpoint's latency
+	endpointLatencyWeight float64 = 0.2
+)
+
+// Endpoint is one of several URLs the Netbox API is reachable at, such as a
+// regional URL or a read replica
+type Endpoint struct {
+	URL *url.URL
+
+	mutex       sync.Mutex
+	failures    int
+	lastFailure time.Time
+	latency     time.Duration
+}
+
+// NewEndpoint returns an Endpoint for the Netbox API at netboxURL
+func NewEndpoint(netboxURL *url.URL) *Endpoint {
+	return &Endpoint{URL: netboxURL}
+}
+
+// Healthy reports whether the last request to the endpoint succeeded, or its
+// last failure was long enough ago that it should be tried again
+func (endpoint *Endpoint) Healthy() bool {
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+	return endpoint.healthy()
+}
+
+func (endpoint *Endpoint) healthy() bool {
+	return endpoint.failures == 0 ||
+		time.Since(endpoint.lastFailure) >= endpointRecheckInterval
+}
+
+// Latency returns the moving average of the time requests to the endpoint
+// took to complete
+func (endpoint *Endpoint) Latency() time.Duration {
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+	return endpoint.latency
+}
+
+func (endpoint *Endpoint) observe(latency time.Duration, failed bool) {
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+	label := endpoint.URL.String()
+	if failed {
+		endpoint.failures++
+		endpoint.lastFailure = time.Now()
+		endpointHealthy.WithLabelValues(label).Set(0)
+		return
+	}
+	endpoint.failures = 0
+	if endpoint.latency == 0 {
+		endpoint.latency = latency
+	} else {
+		endpoint.latency = time.Duration(
+			float64(endpoint.latency)*(1-endpointLatencyWeight) +
+				float64(latency)*endpointLatencyWeight,
+		)
+	}
+	endpointHealthy.WithLabelValues(label).Set(1)
+	endpointLatency.WithLabelValues(label).Set(endpoint.latency.Seconds())
+}
+
+// preferredEndpoints returns the endpoints in the order they should be tried:
+// healthy endpoints by lowest latency, then the endpoints that failed by the
+// longest time since their last failure
+func (requestClient *APIRequestClient) preferredEndpoints() []*Endpoint {
+	type candidate struct {
+		endpoint    *Endpoint
+		healthy     bool
+		latency     time.Duration
+		lastFailure time.Time
+	}
+	candidates := make([]candidate, 0, len(requestClient.Endpoints))
+	for _, endpoint := range requestClient.Endpoints {
+		endpoint.mutex.Lock()
+		candidates = append(candidates, candidate{
+			endpoint:    endpoint,
+			healthy:     endpoint.healthy(),
+			latency:     endpoint.latency,
+			lastFailure: endpoint.lastFailure,
+		})
+		endpoint.mutex.Unlock()
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		switch {
+		case a.healthy && !b.healthy:
+			return -1
+		case !a.healthy && b.healthy:
+			return 1
+		case a.healthy:
+			return cmp.Compare(a.latency, b.latency)
+		default:
+			return a.lastFailure.Compare(b.lastFailure)
+		}
+	})
+	out := make([]*Endpoint, len(candidates))
+	for i, candidate := range candidates {
+		out[i] = candidate.endpoint
+	}
+	return out
+}
+
+// relativeURL returns the part of requestURL that follows the URL of the
+// endpoint it was built from, or false if it was not built from any endpoint.
+// Paths are compared by segment, so that an endpoint at /netbox is not taken
+// for the base of a request to /netbox2.
+func (requestClient *APIRequestClient) relativeURL(
+	requestURL string,
+) (string, bool) {
+	parsed, err := url.Parse(requestURL)
+	if err != nil {
+		return "", false
+	}
+	path := "/" + strings.TrimPrefix(parsed.EscapedPath(), "/")
+	for _, endpoint := range requestClient.Endpoints {
+		if parsed.Scheme != endpoint.URL.Scheme ||
+			!strings.EqualFold(parsed.Host, endpoint.URL.Host) {
+			continue
+		}
+		// a URL joined onto one without a path has no leading slash
+		base := "/" + strings.Trim(endpoint.URL.EscapedPath(), "/")
+		base = strings.TrimSuffix(base, "/")
+		relative, ok := strings.CutPrefix(path, base)
+		if !ok || (relative != "" && !strings.HasPrefix(relative, "/")) {
+			continue
+		}
+		if parsed.RawQuery != "" {
+			relative += "?" + parsed.RawQuery
+		}
+		return relative, true
+	}
+	return "", false
+}
+
+// doGetFailover makes a request to the preferred endpoint, failing over to the
+// other endpoints on connection errors or server errors
+func doGetFailover(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	endpoint string,
+	requestURL string,
+) (*http.Response, error) {
+	if len(requestClient.Endpoints) < 2 {
+		return doGetAttempt(ctx, requestClient, endpoint, requestURL)
+	}
+	relative, ok := requestClient.relativeURL(requestURL)
+	if !ok {
+		return doGetAttempt(ctx, requestClient, endpoint, requestURL)
+	}
+
+	var (
+		response *http.Response
+		err      error
+		previous *Endpoint
+	)
+	for _, candidate := range requestClient.preferredEndpoints() {
+		if previous != nil {
+			endpointFailovers.WithLabelValues(previous.URL.String()).Inc()
+			discardResponse(response)
+		}
+		previous = candidate
+		base := strings.TrimSuffix(candidate.URL.String(), "/")
+		start := time.Now()
+		response, err = doGetAttempt(
+			ctx,
+			requestClient,
+			endpoint,
+			base+relative,
+		)
+		if ctx.Err() != nil {
+			return response, err
+		}
+		failed := unavailable(response, err)
+		candidate.observe(time.Since(start), failed)
+		if !failed {
+			return response, err
+		}
+	}
+	return response, err
+}
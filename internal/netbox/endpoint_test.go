@@ -0,0 +1,141 @@
+package netbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestEndpoint(
+	t *testing.T,
+	requests *atomic.Int32,
+	status int,
+) *Endpoint {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+			w.Write([]byte(`{"count": 0, "results": []}`))
+		},
+	))
+	t.Cleanup(server.Close)
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEndpoint(serverURL.JoinPath("api", "plugins", "netbox-dns"))
+}
+
+func TestEndpointFailover(t *testing.T) {
+	var failingRequests, healthyRequests atomic.Int32
+	failing := newTestEndpoint(t, &failingRequests, http.StatusBadGateway)
+	healthy := newTestEndpoint(t, &healthyRequests, http.StatusOK)
+	requestClient := &APIRequestClient{
+		Client:    http.DefaultClient,
+		NetboxURL: failing.URL,
+		Token:     "sometoken",
+		Endpoints: []*Endpoint{failing, healthy},
+	}
+	ctx := context.Background()
+
+	if _, err := GetZones(ctx, requestClient); err != nil {
+		t.Fatalf("expected failover to succeed, got %v", err)
+	}
+	if failingRequests.Load() != 1 || healthyRequests.Load() != 1 {
+		t.Errorf(
+			"expected 1 request to each endpoint, got %d and %d",
+			failingRequests.Load(),
+			healthyRequests.Load(),
+		)
+	}
+	if failing.Healthy() {
+		t.Error("expected failing endpoint to be unhealthy")
+	}
+	if !healthy.Healthy() {
+		t.Error("expected healthy endpoint to be healthy")
+	}
+
+	// the failing endpoint is no longer preferred
+	if _, err := GetZones(ctx, requestClient); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if failingRequests.Load() != 1 || healthyRequests.Load() != 2 {
+		t.Errorf(
+			"expected requests to prefer healthy endpoint, got %d and %d",
+			failingRequests.Load(),
+			healthyRequests.Load(),
+		)
+	}
+}
+
+func TestEndpointAllFailing(t *testing.T) {
+	var firstRequests, secondRequests atomic.Int32
+	first := newTestEndpoint(t, &firstRequests, http.StatusServiceUnavailable)
+	second := newTestEndpoint(t, &secondRequests, http.StatusBadGateway)
+	requestClient := &APIRequestClient{
+		Client:    http.DefaultClient,
+		NetboxURL: first.URL,
+		Token:     "sometoken",
+		Endpoints: []*Endpoint{first, second},
+	}
+	if _, err := GetZones(context.Background(), requestClient); err == nil {
+		t.Fatal("expected error, got none")
+	}
+	if firstRequests.Load() != 1 || secondRequests.Load() != 1 {
+		t.Errorf(
+			"expected 1 request to each endpoint, got %d and %d",
+			firstRequests.Load(),
+			secondRequests.Load(),
+		)
+	}
+}
+
+func TestRelativeURL(t *testing.T) {
+	parse := func(raw string) *url.URL {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	requestClient := &APIRequestClient{
+		Endpoints: []*Endpoint{
+			NewEndpoint(parse("https://a.example.com/netbox")),
+			NewEndpoint(parse("https://b.example.com/netbox/")),
+			NewEndpoint(parse("https://d.example.com").JoinPath("api")),
+		},
+	}
+	tests := []struct {
+		url  string
+		want string
+		ok   bool
+	}{
+		{"https://a.example.com/netbox/zones/?limit=1", "/zones/?limit=1", true},
+		{"https://b.example.com/netbox/records/", "/records/", true},
+		{"https://a.example.com/netbox", "", true},
+		{"https://a.example.com/netbox2/zones/", "", false},
+		{"https://c.example.com/netbox/zones/", "", false},
+		{"http://a.example.com/netbox/zones/", "", false},
+		{"https://d.example.com/api/zones/", "/zones/", true},
+	}
+	for _, tt := range tests {
+		got, ok := requestClient.relativeURL(tt.url)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf(
+				"relativeURL(%q): expected %q, %t, got %q, %t",
+				tt.url,
+				tt.want,
+				tt.ok,
+				got,
+				ok,
+			)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Export is the format read by FileBackend: the results of listing the zones
+// and records endpoints of the Netbox DNS plugin API
+type Export struct {
+	Zones   []Zone   `json:"zones"`
+	Records []Record `json:"records"`
+}
+
+// FileBackend serves zones and records from a JSON export of the Netbox API,
+// so that the plugin can run without access to Netbox
+type FileBackend struct {
+	zones   []Zone
+	records []Record
+	loaded  time.Time
+}
+
+// NewFileBackend reads the export at path. Records reference their zone by ID,
+// or by name if the reference has no ID, and records without an FQDN have it
+// derived from their name and zone.
+func NewFileBackend(path string) (*FileBackend, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("could not decode %q: %w", path, err)
+	}
+	return newFileBackend(export)
+}
+
+func newFileBackend(export Export) (*FileBackend, error) {
+	// zones without an ID are given one, as records are matched to their zone
+	// by ID
+	nextID := 1
+	for _, zone := range export.Zones {
+		nextID = max(nextID, zone.ID+1)
+	}
+	zonesByID := make(map[int]Zone, len(export.Zones))
+	zonesByName := make(map[string]Zone, len(export.Zones))
+	for k, zone := range export.Zones {
+		if zone.ID == 0 {
+			zone.ID = nextID
+			export.Zones[k].ID = nextID
+			nextID++
+		}
+		zonesByID[zone.ID] = zone
+		zonesByName[NormalizeName(zone.Name)] = zone
+	}
+	records := make([]Record, 0, len(export.Records))
+	for _, record := range export.Records {
+		zone, ok := zonesByID[record.Zone.ID]
+		if record.Zone.ID == 0 {
+			zone, ok = zonesByName[NormalizeName(record.Zone.Name)]
+		}
+		if !ok {
+			return nil, fmt.Errorf(
+				"record %d [%s] %q references unknown zone %q",
+				record.ID,
+				record.Type,
+				record.Name,
+				record.Zone.Name,
+			)
+		}
+		record.Zone = zone
+		if record.FQDN == "" {
+			record.FQDN = dns.Fqdn(zone.Name)
+			if record.Name != "@" {
+				record.FQDN = dns.Fqdn(record.Name + "." + zone.Name)
+			}
+		}
+		if record.TTL == nil {
+			ttl := zone.DefaultTTL
+			record.TTL = &ttl
+		}
+		records = append(records, record)
+	}
+	return &FileBackend{
+		zones:   export.Zones,
+		records: records,
+		loaded:  time.Now(),
+	}, nil
+}
+
+// Zones implements the Backend interface
+func (backend *FileBackend) Zones(context.Context) ([]Zone, error) {
+	return append([]Zone(nil), backend.zones...), nil
+}
+
+// Records implements the Backend interface, applying the same filters as the
+// Netbox API
+func (backend *FileBackend) Records(
+	_ context.Context,
+	query *RecordQuery,
+) ([]Record, error) {
+	out := make([]Record, 0)
+	for _, record := range backend.records {
+		if query.matches(record) {
+			out = append(out, record)
+		}
+	}
+	return out, nil
+}
+
+// LastContact implements the Backend interface, returning when the export was
+// read
+func (backend *FileBackend) LastContact() time.Time {
+	return backend.loaded
+}
+
+func (recordQuery *RecordQuery) matches(record Record) bool {
+	if len(recordQuery.FQDN) != 0 && !containsName(
+		recordQuery.FQDN,
+		record.FQDN,
+	) {
+		return false
+	}
+	if recordQuery.Name != "" && recordQuery.Name != record.Name {
+		return false
+	}
+	if len(recordQuery.Type) != 0 && !containsName(
+		recordQuery.Type,
+		record.Type,
+	) {
+		return false
+	}
+	if recordQuery.Zone != nil && recordQuery.Zone.ID != record.Zone.ID {
+		return false
+	}
+	return true
+}
+
+func containsName(names []string, name string) bool {
+	name = NormalizeName(name)
+	for _, candidate := range names {
+		if NormalizeName(candidate) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeName lowercases name and removes any trailing dot so that names are
+// compared the way Netbox does
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
@@ -0,0 +1,103 @@
+package netbox
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFileBackend(t *testing.T) {
+	backend, err := NewFileBackend("../../.testing/export.json")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	zones, err := backend.Zones(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(zones) != 1 || zones[0].Name != "example.com" {
+		t.Fatalf("expected zone %q, got %v", "example.com", zones)
+	}
+
+	tests := []struct {
+		name    string
+		query   RecordQuery
+		wantIDs []int
+		wantTTL []uint32
+	}{
+		{
+			"origin",
+			RecordQuery{Name: "@", Type: []string{"SOA", "NS"}, Zone: &zones[0]},
+			[]int{1, 2},
+			[]uint32{86400, 3600},
+		},
+		{
+			"fqdn without trailing dot",
+			RecordQuery{FQDN: []string{"DNS01.example.com"}, Type: []string{"AAAA"}},
+			[]int{4},
+			[]uint32{300},
+		},
+		{
+			"several fqdns",
+			RecordQuery{FQDN: []string{"www.example.com", "dns01.example.com"}},
+			[]int{3, 4, 5},
+			[]uint32{3600, 300, 3600},
+		},
+		{
+			"other zone",
+			RecordQuery{FQDN: []string{"www.example.com"}, Zone: &Zone{ID: 2}},
+			nil,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := backend.Records(context.Background(), &tt.query)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(records) != len(tt.wantIDs) {
+				t.Fatalf("expected %d records, got %d", len(tt.wantIDs), len(records))
+			}
+			for k, record := range records {
+				if record.ID != tt.wantIDs[k] || *record.TTL != tt.wantTTL[k] {
+					t.Errorf(
+						"expected record %d with ttl %d, got %d with ttl %d",
+						tt.wantIDs[k],
+						tt.wantTTL[k],
+						record.ID,
+						*record.TTL,
+					)
+				}
+			}
+		})
+	}
+}
+
+func TestFileBackendZoneByName(t *testing.T) {
+	backend, err := newFileBackend(Export{
+		Zones: []Zone{{Name: "example.com", DefaultTTL: 60}},
+		Records: []Record{
+			{Zone: Zone{Name: "example.com."}, Name: "www", Type: "A"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	record := backend.records[0]
+	if record.FQDN != "www.example.com." {
+		t.Errorf("expected fqdn %q, got %q", "www.example.com.", record.FQDN)
+	}
+	if record.Zone.ID == 0 || record.Zone.ID != backend.zones[0].ID {
+		t.Errorf("expected zone id %d, got %d", backend.zones[0].ID, record.Zone.ID)
+	}
+	if *record.TTL != 60 {
+		t.Errorf("expected ttl %d, got %d", 60, *record.TTL)
+	}
+
+	_, err = newFileBackend(Export{
+		Records: []Record{{Zone: Zone{Name: "example.net"}, Name: "www"}},
+	})
+	if err == nil {
+		t.Error("expected an error for a record in an unknown zone")
+	}
+}
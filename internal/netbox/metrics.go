@@ -0,0 +1,84 @@
+package netbox
+
+import (
+	"github.com/coredns/coredns/plugin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsSubsystem string = "netboxdns"
+
+// Variables declared for monitoring.
+var (
+	requestCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_requests_total",
+		Help:      "Counter of requests made to the Netbox API per endpoint and status code.",
+	}, []string{"endpoint", "code"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_request_duration_seconds",
+		Buckets:   plugin.TimeBuckets,
+		Help:      "Histogram of the time each request to the Netbox API took per endpoint and status code.",
+	}, []string{"endpoint", "code"})
+
+	pagesFetched = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_pages_fetched",
+		Buckets:   []float64{1, 2, 3, 5, 10, 20, 50},
+		Help:      "Histogram of the number of pages fetched per paginated Netbox API listing.",
+	}, []string{"endpoint"})
+
+	requestsCoalesced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_requests_coalesced_total",
+		Help:      "Counter of Netbox API requests saved by sharing an identical request already in flight.",
+	}, []string{"endpoint"})
+
+	requestRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_retries_total",
+		Help:      "Counter of requests to the Netbox API that were retried.",
+	}, []string{"endpoint"})
+
+	breakerOpenGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_circuit_breaker_open",
+		Help:      "Whether the circuit breaker for each Netbox API client is open (1) or closed (0).",
+	}, []string{"url"})
+
+	endpointHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_endpoint_healthy",
+		Help:      "Whether the last request to each Netbox API URL succeeded (1) or failed (0).",
+	}, []string{"url"})
+
+	endpointLatency = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_endpoint_latency_seconds",
+		Help:      "Moving average of the time requests to each Netbox API URL took.",
+	}, []string{"url"})
+
+	endpointFailovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_failovers_total",
+		Help:      "Counter of requests that failed over from each Netbox API URL to another.",
+	}, []string{"url"})
+
+	lastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: metricsSubsystem,
+		Name:      "api_last_success_timestamp_seconds",
+		Help:      "Unix timestamp of the last request the Netbox API answered successfully per client.",
+	}, []string{"url"})
+)
@@ -0,0 +1,50 @@
+package netbox
+
+import (
+	"context"
+	"net/url"
+)
+
+// NameServer is a nameserver that zones can be served by
+type NameServer struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+const endpointNameServers string = "nameservers"
+
+func urlNameServers(netboxurl *url.URL) *url.URL {
+	return netboxurl.JoinPath("nameservers", "/")
+}
+
+// GetNameServers returns every nameserver
+func GetNameServers(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+) ([]NameServer, error) {
+	span, ctx := StartSpan(ctx, "netbox.GetNameServers")
+	defer span.Finish()
+	requestUrl := urlNameServers(requestClient.NetboxURL)
+	return getMany[NameServer](
+		ctx,
+		requestClient,
+		endpointNameServers,
+		requestUrl.String(),
+	)
+}
+
+// CreateNameServer creates a nameserver named name and returns it
+func CreateNameServer(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	name string,
+) (NameServer, error) {
+	requestUrl := urlNameServers(requestClient.NetboxURL)
+	return create[NameServer](
+		ctx,
+		requestClient,
+		endpointNameServers,
+		requestUrl.String(),
+		NameServer{Name: name},
+	)
+}
@@ -1,20 +1,26 @@
 package netbox
 
 import (
+	"context"
 	"net/url"
 	"strconv"
 )
 
 type Record struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
 	Type  string  `json:"type"`
 	Value string  `json:"value"`
 	TTL   *uint32 `json:"ttl"`
 	Zone  Zone    `json:"zone"`
 	FQDN  string  `json:"fqdn"`
+	// Managed records, such as the SOA record of a zone, are created by
+	// netbox-plugin-dns and cannot be changed through the API
+	Managed bool `json:"managed"`
 }
 
 type RecordQuery struct {
-	FQDN string
+	FQDN []string
 	Name string
 	Type []string
 	Zone *Zone
@@ -23,8 +29,10 @@ type RecordQuery struct {
 func (recordQuery *RecordQuery) Encode() string {
 	out := url.Values{}
 
-	if recordQuery.FQDN != "" {
-		out.Set("fqdn", recordQuery.FQDN)
+	if len(recordQuery.FQDN) != 0 {
+		for _, fqdn := range recordQuery.FQDN {
+			out.Add("fqdn", fqdn)
+		}
 	}
 
 	if recordQuery.Name != "" {
@@ -44,17 +52,32 @@ func (recordQuery *RecordQuery) Encode() string {
 	return out.Encode()
 }
 
+const endpointRecords string = "records"
+
 func urlRecords(netboxurl *url.URL) *url.URL {
 	return netboxurl.JoinPath("records", "/")
 }
 
+func urlRecordID(netboxurl *url.URL, id int) *url.URL {
+	return netboxurl.JoinPath("records", "/", strconv.Itoa(id), "/")
+}
+
 func GetRecordsQuery(
+	ctx context.Context,
 	requestClient *APIRequestClient,
 	query *RecordQuery,
 ) ([]Record, error) {
+	span, ctx := StartSpan(ctx, "netbox.GetRecordsQuery")
+	defer span.Finish()
 	requestUrl := urlRecords(requestClient.NetboxURL)
 	requestUrl.RawQuery = query.Encode()
-	records, err := getMany[Record](requestClient, requestUrl.String())
+	span.SetTag("query", requestUrl.RawQuery)
+	records, err := getMany[Record](
+		ctx,
+		requestClient,
+		endpointRecords,
+		requestUrl.String(),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +88,7 @@ func GetRecordsQuery(
 			}
 		}
 	} else {
-		resolvedRecords, err := resolveRecordTTLs(requestClient, records)
+		resolvedRecords, err := resolveRecordTTLs(ctx, requestClient, records)
 		if err != nil {
 			return records, err
 		}
@@ -75,6 +98,7 @@ func GetRecordsQuery(
 }
 
 func resolveRecordTTLs(
+	ctx context.Context,
 	requestClient *APIRequestClient,
 	records []Record,
 ) ([]Record, error) {
@@ -88,7 +112,12 @@ func resolveRecordTTLs(
 			continue
 		}
 		zoneUrl := urlZoneID(requestClient.NetboxURL, record.Zone.ID)
-		zone, err := get[Zone](requestClient, zoneUrl.String())
+		zone, err := get[Zone](
+			ctx,
+			requestClient,
+			endpointZones,
+			zoneUrl.String(),
+		)
 		if err != nil {
 			return records, err
 		}
@@ -97,3 +126,56 @@ func resolveRecordTTLs(
 	}
 	return records, nil
 }
+
+// RecordRequest is the body of a request creating or updating a record. A nil
+// TTL uses the default TTL of the zone.
+type RecordRequest struct {
+	Zone  int     `json:"zone"`
+	Name  string  `json:"name"`
+	Type  string  `json:"type"`
+	Value string  `json:"value"`
+	TTL   *uint32 `json:"ttl"`
+}
+
+// CreateRecord creates a record and returns it
+func CreateRecord(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	record *RecordRequest,
+) (Record, error) {
+	requestUrl := urlRecords(requestClient.NetboxURL)
+	return create[Record](
+		ctx,
+		requestClient,
+		endpointRecords,
+		requestUrl.String(),
+		record,
+	)
+}
+
+// UpdateRecord replaces the fields of the record with id and returns it
+func UpdateRecord(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	id int,
+	record *RecordRequest,
+) (Record, error) {
+	requestUrl := urlRecordID(requestClient.NetboxURL, id)
+	return update[Record](
+		ctx,
+		requestClient,
+		endpointRecords,
+		requestUrl.String(),
+		record,
+	)
+}
+
+// DeleteRecord deletes the record with id
+func DeleteRecord(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	id int,
+) error {
+	requestUrl := urlRecordID(requestClient.NetboxURL, id)
+	return remove(ctx, requestClient, endpointRecords, requestUrl.String())
+}
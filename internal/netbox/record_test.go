@@ -0,0 +1,50 @@
+package netbox
+
+import "testing"
+
+func TestRecordQueryEncode(t *testing.T) {
+	tests := []struct {
+		name  string
+		query RecordQuery
+		want  string
+	}{
+		{
+			"empty",
+			RecordQuery{},
+			"",
+		},
+		{
+			"single fqdn",
+			RecordQuery{
+				FQDN: []string{"www.example.com"},
+				Type: []string{"A", "CNAME"},
+			},
+			"fqdn=www.example.com&type=A&type=CNAME",
+		},
+		{
+			"multiple fqdn",
+			RecordQuery{
+				FQDN: []string{"dns01.example.com", "dns02.example.com"},
+				Type: []string{"AAAA"},
+				Zone: &Zone{ID: 4},
+			},
+			"fqdn=dns01.example.com&fqdn=dns02.example.com&type=AAAA&zone_id=4",
+		},
+		{
+			"origin name",
+			RecordQuery{
+				Name: "@",
+				Type: []string{"SOA", "NS"},
+				Zone: &Zone{ID: 1},
+			},
+			"name=%40&type=SOA&type=NS&zone_id=1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Encode(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
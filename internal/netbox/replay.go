@@ -0,0 +1,180 @@
+package netbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// recordedOrigin replaces the scheme and host of the recorded instance in
+// response bodies, so that pagination links do not change with the port of the
+// instance each time fixtures are recorded
+const recordedOrigin string = "http://netbox.invalid"
+
+// Interaction is a request made to the Netbox API and the response it returned.
+// The Authorization header is not recorded, so fixtures do not hold tokens.
+type Interaction struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	Status      int             `json:"status"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body"`
+}
+
+// interactionKey identifies a request by its method, path, and query, ignoring
+// the host so that fixtures recorded from one instance can be replayed against
+// any URL
+func interactionKey(request *http.Request) string {
+	requestUrl := *request.URL
+	requestUrl.Scheme = ""
+	requestUrl.Host = ""
+	requestUrl.RawQuery = requestUrl.Query().Encode()
+	return request.Method + " " + requestUrl.String()
+}
+
+// Recorder is an http.RoundTripper that captures the responses of the Netbox
+// API so that they can be saved as fixtures and served by a ReplayTransport
+type Recorder struct {
+	// Transport makes the requests, or http.DefaultTransport if nil
+	Transport http.RoundTripper
+
+	mutex        sync.Mutex
+	interactions map[string]Interaction
+}
+
+// Record captures every response returned to requestClient from now on in the
+// Recorder returned
+func (requestClient *APIRequestClient) Record() *Recorder {
+	recorder := &Recorder{Transport: requestClient.Client.Transport}
+	requestClient.Client.Transport = recorder
+	return recorder
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (recorder *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	transport := recorder.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	response, err := transport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	// bodies that are not JSON, such as error pages from a proxy, are kept as
+	// a JSON string
+	if json.Valid(body) {
+		origin := request.URL.Scheme + "://" + request.URL.Host
+		body = bytes.ReplaceAll(body, []byte(origin), []byte(recordedOrigin))
+	} else {
+		body, _ = json.Marshal(string(body))
+	}
+	key := interactionKey(request)
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if recorder.interactions == nil {
+		recorder.interactions = make(map[string]Interaction)
+	}
+	recorder.interactions[key] = Interaction{
+		Method:      request.Method,
+		URL:         key[len(request.Method)+1:],
+		Status:      response.StatusCode,
+		ContentType: response.Header.Get("Content-Type"),
+		Body:        body,
+	}
+	return response, nil
+}
+
+// Save writes the recorded interactions to path, ordered by request so that
+// re-recording produces small diffs
+func (recorder *Recorder) Save(path string) error {
+	recorder.mutex.Lock()
+	keys := make([]string, 0, len(recorder.interactions))
+	for key := range recorder.interactions {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	interactions := make([]Interaction, 0, len(keys))
+	for _, key := range keys {
+		interactions = append(interactions, recorder.interactions[key])
+	}
+	recorder.mutex.Unlock()
+
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(interactions); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data.Bytes(), 0o644)
+}
+
+// ReplayTransport is an http.RoundTripper that serves the responses saved by a
+// Recorder instead of making requests
+type ReplayTransport struct {
+	interactions map[string]Interaction
+}
+
+// NewReplayTransport reads the interactions saved at path
+func NewReplayTransport(path string) (*ReplayTransport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("could not decode %q: %w", path, err)
+	}
+	transport := &ReplayTransport{
+		interactions: make(map[string]Interaction, len(interactions)),
+	}
+	for _, interaction := range interactions {
+		transport.interactions[interaction.Method+" "+interaction.URL] = interaction
+	}
+	return transport, nil
+}
+
+// RoundTrip implements the http.RoundTripper interface. A request that was not
+// recorded returns an error.
+func (transport *ReplayTransport) RoundTrip(
+	request *http.Request,
+) (*http.Response, error) {
+	key := interactionKey(request)
+	interaction, ok := transport.interactions[key]
+	if !ok {
+		return nil, fmt.Errorf("no recorded response for %s", key)
+	}
+	body := []byte(interaction.Body)
+	var text string
+	if json.Unmarshal(body, &text) == nil {
+		body = []byte(text)
+	}
+	header := http.Header{}
+	if interaction.ContentType != "" {
+		header.Set("Content-Type", interaction.ContentType)
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(interaction.Status) + " " + http.StatusText(interaction.Status),
+		StatusCode:    interaction.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}, nil
+}
@@ -0,0 +1,69 @@
+package netbox
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	requestClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") == "" {
+			next := "http://" + r.Host + r.URL.Path + "?limit=1&offset=1"
+			w.Write([]byte(`{"count": 2, "next": "` + next + `", "results": [{"id": 1, "name": "example.com"}]}`))
+			return
+		}
+		w.Write([]byte(`{"count": 2, "results": [{"id": 2, "name": "example.net"}]}`))
+	})
+	recorder := requestClient.Record()
+	recorded, err := GetZones(context.Background(), requestClient)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), requestClient.NetboxURL.Host) {
+		t.Errorf("expected the recorded host to be left out of %s", data)
+	}
+
+	transport, err := NewReplayTransport(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	replayClient := &APIRequestClient{
+		Client:    &http.Client{Transport: transport},
+		NetboxURL: &url.URL{Scheme: "http", Host: "netbox.invalid"},
+		Token:     "othertoken",
+	}
+	replayed, err := GetZones(context.Background(), replayClient)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(replayed) != len(recorded) {
+		t.Fatalf("expected %d zones, got %d", len(recorded), len(replayed))
+	}
+	for k := range recorded {
+		if replayed[k].Name != recorded[k].Name {
+			t.Errorf("expected zone %q, got %q", recorded[k].Name, replayed[k].Name)
+		}
+	}
+
+	_, err = GetRecordsQuery(
+		context.Background(),
+		replayClient,
+		&RecordQuery{Name: "www"},
+	)
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("expected an error for an unrecorded request, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package netbox
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultRetryBackoff    time.Duration = time.Millisecond * 100
+	DefaultRetryMaxBackoff time.Duration = time.Second * 2
+)
+
+// RetryPolicy configures how requests that fail with a connection error or a
+// transient status are retried. The zero value disables retries.
+type RetryPolicy struct {
+	// Attempts is the number of times a request is retried after it fails
+	Attempts int
+	// Backoff is the delay before the first retry, doubled for each retry
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration
+}
+
+// delay returns how long to wait before retrying after the given attempt. The
+// exponential backoff has full jitter applied. A delay requested by the server
+// through Retry-After is used instead, but never exceeds MaxBackoff: a server
+// asking for a longer wait is retried after MaxBackoff.
+func (policy RetryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultRetryMaxBackoff
+	}
+	if retryAfter > 0 {
+		return min(retryAfter, maxBackoff)
+	}
+	backoff := policy.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	// double one step at a time rather than shifting by attempt, which would
+	// overflow for large attempt counts
+	for range attempt {
+		if backoff >= maxBackoff {
+			break
+		}
+		backoff <<= 1
+	}
+	backoff = min(backoff, maxBackoff)
+	return rand.N(backoff) + 1
+}
+
+// retryable reports whether a request that returned response and err should be
+// retried, and how long the server asked to wait before doing so
+func retryable(
+	ctx context.Context,
+	response *http.Response,
+	err error,
+) (bool, time.Duration) {
+	if ctx.Err() != nil {
+		return false, 0
+	}
+	if err != nil {
+		return true, 0
+	}
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true, retryAfter(response.Header.Get("Retry-After"))
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true, 0
+	}
+	return false, 0
+}
+
+// retryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// unavailable reports whether a request that returned response and err counts
+// as a failure of the Netbox API to the circuit breaker
+func unavailable(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return response.StatusCode == http.StatusTooManyRequests ||
+		response.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,174 @@
+package netbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *APIRequestClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &APIRequestClient{
+		Client:    server.Client(),
+		NetboxURL: serverURL,
+		Token:     "sometoken",
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var requests atomic.Int32
+	requestClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"count": 1, "results": [{"id": 1, "name": "example.com"}]}`))
+	})
+	requestClient.Retry = RetryPolicy{
+		Attempts: 3,
+		Backoff:  time.Millisecond,
+	}
+	zones, err := GetZones(context.Background(), requestClient)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(zones) != 1 {
+		t.Errorf("expected 1 zone, got %d", len(zones))
+	}
+	if requests.Load() != 3 {
+		t.Errorf("expected 3 requests, got %d", requests.Load())
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var requests atomic.Int32
+	requestClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	requestClient.Retry = RetryPolicy{
+		Attempts: 2,
+		Backoff:  time.Millisecond,
+	}
+	_, err := GetZones(context.Background(), requestClient)
+	var responseErr *ResponseError
+	if !errors.As(err, &responseErr) {
+		t.Fatalf("expected response error, got %v", err)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("expected 3 requests, got %d", requests.Load())
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	var requests atomic.Int32
+	requestClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	})
+	requestClient.Retry = RetryPolicy{
+		Attempts: 2,
+		Backoff:  time.Millisecond,
+	}
+	if _, err := GetZones(context.Background(), requestClient); err == nil {
+		t.Fatal("expected error, got none")
+	}
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", requests.Load())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", time.Second * 3},
+		{"-1", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.value); got != tt.want {
+			t.Errorf("retryAfter(%q): expected %s, got %s", tt.value, tt.want, got)
+		}
+	}
+	policy := RetryPolicy{MaxBackoff: time.Second}
+	if got := policy.delay(0, time.Minute); got != time.Second {
+		t.Errorf("expected retry-after to be capped to 1s, got %s", got)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{}
+	for _, attempt := range []int{0, 1, 10, 40, 64, 1000} {
+		got := policy.delay(attempt, 0)
+		if got <= 0 || got > DefaultRetryMaxBackoff {
+			t.Errorf(
+				"attempt %d: expected delay in (0, %s], got %s",
+				attempt,
+				DefaultRetryMaxBackoff,
+				got,
+			)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	var healthy atomic.Bool
+	requestClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"count": 0, "results": []}`))
+	})
+	requestClient.Breaker = &CircuitBreaker{
+		Threshold: 2,
+		Cooldown:  time.Millisecond * 50,
+	}
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := GetZones(ctx, requestClient); err == nil {
+			t.Fatal("expected error, got none")
+		}
+	}
+	if _, err := GetZones(ctx, requestClient); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected %v, got %v", ErrCircuitOpen, err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("expected 2 requests while open, got %d", requests.Load())
+	}
+	gauge := breakerOpenGauge.WithLabelValues(requestClient.metricsURL())
+	if testutil.ToFloat64(gauge) != 1 {
+		t.Error("expected the breaker of the client to be reported open")
+	}
+
+	healthy.Store(true)
+	time.Sleep(time.Millisecond * 60)
+	if _, err := GetZones(ctx, requestClient); err != nil {
+		t.Fatalf("expected probe to succeed, got %v", err)
+	}
+	if _, err := GetZones(ctx, requestClient); err != nil {
+		t.Fatalf("expected breaker to be closed, got %v", err)
+	}
+	if testutil.ToFloat64(gauge) != 0 {
+		t.Error("expected the breaker of the client to be reported closed")
+	}
+}
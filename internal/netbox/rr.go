@@ -0,0 +1,100 @@
+package netbox
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var txtMultiValueRegexp *regexp.Regexp
+
+func init() {
+	txtMultiValueRegexp = regexp.MustCompile(`[^\s"']+|"([^"]*)"|'([^']*)`)
+}
+
+// RecordError is returned when the value of a Netbox record cannot be parsed
+// into a resource record
+type RecordError struct {
+	Record Record
+	Err    error
+}
+
+func (recordErr *RecordError) Error() string {
+	return fmt.Sprintf(
+		"could not parse record %d [%s] %q: %v",
+		recordErr.Record.ID,
+		recordErr.Record.Type,
+		recordErr.Record.FQDN,
+		recordErr.Err,
+	)
+}
+
+func (recordErr *RecordError) Unwrap() error {
+	return recordErr.Err
+}
+
+// RecordsToRR converts records into resource records, stopping at the first
+// record that cannot be parsed
+func RecordsToRR(records []Record) ([]dns.RR, error) {
+	out := make([]dns.RR, 0, len(records))
+	for _, record := range records {
+		rr, err := RecordToRR(record)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, rr)
+	}
+	return out, nil
+}
+
+// RecordToRR converts a record into a resource record. The record's TTL must
+// be set.
+func RecordToRR(record Record) (dns.RR, error) {
+	qtype := dns.StringToType[record.Type]
+	switch qtype {
+	case dns.TypeTXT:
+		return recordToTXT(record), nil
+	default:
+		rrStr := fmt.Sprintf(
+			"%s %d IN %s %s",
+			record.FQDN,
+			*record.TTL,
+			record.Type,
+			record.Value,
+		)
+		rr, err := dns.NewRR(rrStr)
+		if err != nil {
+			return nil, &RecordError{Record: record, Err: err}
+		}
+		return rr, nil
+	}
+}
+
+func recordToTXT(record Record) *dns.TXT {
+	txt := make([]string, 0)
+	if strings.HasPrefix(record.Value, `"`) {
+		values := txtMultiValueRegexp.FindAllString(record.Value, -1)
+		for i := range values {
+			values[i] = strings.Trim(values[i], `"`)
+			values[i] = strings.ReplaceAll(values[i], "\\r\\n", "")
+			values[i] = strings.ReplaceAll(values[i], "\\n", "")
+			values[i] = strings.TrimSpace(values[i])
+			if values[i] != "" {
+				txt = append(txt, values[i])
+			}
+		}
+	} else {
+		txt = append(txt, record.Value)
+	}
+	return &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   record.FQDN,
+			Ttl:    *record.TTL,
+			Class:  dns.ClassINET,
+			Rrtype: dns.TypeTXT,
+		},
+		Txt: txt,
+	}
+}
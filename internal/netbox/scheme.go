@@ -0,0 +1,193 @@
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tokenV2Prefix is the prefix of v2 API tokens introduced in Netbox 4.5
+const tokenV2Prefix string = "nbt_"
+
+// TokenScheme is the scheme used in the Authorization header of requests
+type TokenScheme int
+
+const (
+	// TokenSchemeAuto uses TokenSchemeBearer for v2 tokens and
+	// TokenSchemeToken for all others
+	TokenSchemeAuto   TokenScheme = iota
+	TokenSchemeToken              // Authorization: Token TOKEN (v1 tokens)
+	TokenSchemeBearer             // Authorization: Bearer TOKEN (v2 tokens)
+)
+
+// StringToTokenScheme maps configuration values to token schemes
+var StringToTokenScheme = map[string]TokenScheme{
+	"auto":   TokenSchemeAuto,
+	"token":  TokenSchemeToken,
+	"bearer": TokenSchemeBearer,
+}
+
+func (scheme TokenScheme) String() string {
+	switch scheme {
+	case TokenSchemeToken:
+		return "Token"
+	case TokenSchemeBearer:
+		return "Bearer"
+	default:
+		return "auto"
+	}
+}
+
+// ErrTokenRejected is returned by ValidateTokenScheme when Netbox rejects the
+// token with every scheme
+var ErrTokenRejected = errors.New(
+	"netbox api rejected the token with every scheme",
+)
+
+// TokenSchemeError is returned by ValidateTokenScheme when Netbox rejects the
+// token with the configured scheme but accepts it with another
+type TokenSchemeError struct {
+	Configured TokenScheme
+	Accepted   TokenScheme
+}
+
+func (schemeErr *TokenSchemeError) Error() string {
+	return fmt.Sprintf(
+		"netbox api rejected the token with scheme %q but accepted it with %q",
+		schemeErr.Configured,
+		schemeErr.Accepted,
+	)
+}
+
+// resolve returns the scheme to use for token
+func (scheme TokenScheme) resolve(token string) TokenScheme {
+	if scheme != TokenSchemeAuto {
+		return scheme
+	}
+	if strings.HasPrefix(token, tokenV2Prefix) {
+		return TokenSchemeBearer
+	}
+	return TokenSchemeToken
+}
+
+func (requestClient *APIRequestClient) authorization() string {
+	token := requestClient.token()
+	return fmt.Sprintf(
+		"%s %s",
+		requestClient.TokenScheme.resolve(token),
+		token,
+	)
+}
+
+// ValidateTokenScheme checks that Netbox accepts the token with the configured
+// scheme at every endpoint. If it does not, the other scheme is tried: when
+// requestClient uses TokenSchemeAuto it is switched to the scheme Netbox
+// accepts, otherwise a TokenSchemeError naming the scheme to configure is
+// returned. Errors connecting to Netbox are returned as is, so callers can
+// decide whether they are fatal.
+func ValidateTokenScheme(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+) error {
+	if len(requestClient.Endpoints) < 2 {
+		return validateTokenScheme(ctx, requestClient, requestClient.NetboxURL)
+	}
+	for _, endpoint := range requestClient.Endpoints {
+		err := validateTokenScheme(ctx, requestClient, endpoint.URL)
+		if err != nil {
+			return fmt.Errorf("%s: %w", endpoint.URL.Redacted(), err)
+		}
+	}
+	return nil
+}
+
+func validateTokenScheme(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	netboxURL *url.URL,
+) error {
+	token := requestClient.token()
+	configured := requestClient.TokenScheme.resolve(token)
+	accepted, err := acceptsScheme(ctx, requestClient, netboxURL, configured, token)
+	if err != nil || accepted {
+		return err
+	}
+	other := TokenSchemeBearer
+	if configured == TokenSchemeBearer {
+		other = TokenSchemeToken
+	}
+	accepted, err = acceptsScheme(ctx, requestClient, netboxURL, other, token)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return ErrTokenRejected
+	}
+	if requestClient.TokenScheme == TokenSchemeAuto {
+		requestClient.TokenScheme = other
+		return nil
+	}
+	return &TokenSchemeError{Configured: configured, Accepted: other}
+}
+
+func acceptsScheme(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	netboxURL *url.URL,
+	scheme TokenScheme,
+	token string,
+) (bool, error) {
+	requestUrl := urlZones(netboxURL)
+	requestUrl.RawQuery = "limit=1"
+	request, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		requestUrl.String(),
+		nil,
+	)
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Authorization", fmt.Sprintf("%s %s", scheme, token))
+	request.Header.Set("User-Agent", requestClient.UserAgent)
+	response, err := requestClient.Client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer discardResponse(response)
+	switch response.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusUnauthorized:
+		return false, nil
+	case http.StatusForbidden:
+		return false, forbiddenError(response)
+	default:
+		return false, responseError(response)
+	}
+}
+
+// forbiddenError returns nil if a 403 response says the token is invalid,
+// which is how Netbox rejects a token sent with the wrong scheme. Any other
+// 403, such as a token lacking permissions, is returned as a ResponseError.
+func forbiddenError(response *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorDetail))
+	var detail struct {
+		Detail string `json:"detail"`
+	}
+	json.Unmarshal(body, &detail)
+	message := strings.ToLower(detail.Detail)
+	if strings.Contains(message, "invalid") && strings.Contains(message, "token") {
+		return nil
+	}
+	return &ResponseError{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Detail:     strings.TrimSpace(string(body)),
+	}
+}
@@ -0,0 +1,124 @@
+package netbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTokenSchemeResolve(t *testing.T) {
+	tests := []struct {
+		scheme TokenScheme
+		token  string
+		want   TokenScheme
+	}{
+		{TokenSchemeAuto, "w5pgWXPqZVmngLN4w4XwuPvZfUC72ytDxnnHgEmI", TokenSchemeToken},
+		{TokenSchemeAuto, "nbt_abc123.def456", TokenSchemeBearer},
+		{TokenSchemeToken, "nbt_abc123.def456", TokenSchemeToken},
+		{TokenSchemeBearer, "sometoken", TokenSchemeBearer},
+	}
+	for _, tt := range tests {
+		if got := tt.scheme.resolve(tt.token); got != tt.want {
+			t.Errorf(
+				"%s scheme for %q: expected %s, got %s",
+				tt.scheme,
+				tt.token,
+				tt.want,
+				got,
+			)
+		}
+	}
+}
+
+func newBearerOnlyClient(t *testing.T) *APIRequestClient {
+	return newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sometoken" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"detail": "Invalid token"}`))
+			return
+		}
+		w.Write([]byte(`{"count": 0, "results": []}`))
+	})
+}
+
+func TestValidateTokenSchemeAuto(t *testing.T) {
+	requestClient := newBearerOnlyClient(t)
+	err := ValidateTokenScheme(context.Background(), requestClient)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if requestClient.TokenScheme != TokenSchemeBearer {
+		t.Errorf(
+			"expected scheme to switch to %s, got %s",
+			TokenSchemeBearer,
+			requestClient.TokenScheme,
+		)
+	}
+}
+
+func TestValidateTokenSchemeMismatch(t *testing.T) {
+	requestClient := newBearerOnlyClient(t)
+	requestClient.TokenScheme = TokenSchemeToken
+	err := ValidateTokenScheme(context.Background(), requestClient)
+	var schemeErr *TokenSchemeError
+	if !errors.As(err, &schemeErr) {
+		t.Fatalf("expected token scheme error, got %v", err)
+	}
+	if schemeErr.Accepted != TokenSchemeBearer {
+		t.Errorf(
+			"expected accepted scheme %s, got %s",
+			TokenSchemeBearer,
+			schemeErr.Accepted,
+		)
+	}
+}
+
+func TestValidateTokenSchemeRejected(t *testing.T) {
+	requestClient := newBearerOnlyClient(t)
+	requestClient.Token = "noop"
+	err := ValidateTokenScheme(context.Background(), requestClient)
+	if !errors.Is(err, ErrTokenRejected) {
+		t.Errorf("expected %v, got %v", ErrTokenRejected, err)
+	}
+}
+
+func TestValidateTokenSchemeEndpoints(t *testing.T) {
+	requestClient := newBearerOnlyClient(t)
+	rejecting := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	requestClient.Endpoints = []*Endpoint{
+		NewEndpoint(requestClient.NetboxURL),
+		NewEndpoint(rejecting.NetboxURL),
+	}
+	err := ValidateTokenScheme(context.Background(), requestClient)
+	if !errors.Is(err, ErrTokenRejected) {
+		t.Fatalf("expected %v, got %v", ErrTokenRejected, err)
+	}
+	if !strings.Contains(err.Error(), rejecting.NetboxURL.Host) {
+		t.Errorf("expected error to name the rejecting endpoint, got %v", err)
+	}
+}
+
+func TestValidateTokenSchemeForbidden(t *testing.T) {
+	requestClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(
+			`{"detail": "You do not have permission to perform this action."}`,
+		))
+	})
+	err := ValidateTokenScheme(context.Background(), requestClient)
+	var responseErr *ResponseError
+	if !errors.As(err, &responseErr) {
+		t.Fatalf("expected response error, got %v", err)
+	}
+	if responseErr.StatusCode != http.StatusForbidden {
+		t.Errorf(
+			"expected status %d, got %d",
+			http.StatusForbidden,
+			responseErr.StatusCode,
+		)
+	}
+}
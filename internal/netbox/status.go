@@ -0,0 +1,150 @@
+package netbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MinimumPluginVersion is the oldest netbox-plugin-dns version supported
+const MinimumPluginVersion string = "0.22.8"
+
+const (
+	endpointStatus string = "status"
+	pluginPackage  string = "netbox_dns"
+)
+
+// Status is the response of the Netbox status API
+type Status struct {
+	NetboxVersion string            `json:"netbox-version"`
+	Plugins       map[string]string `json:"plugins"`
+}
+
+func urlStatus(netboxurl *url.URL) *url.URL {
+	// NetboxURL points at the netbox-dns plugin API under /api/plugins/
+	return netboxurl.JoinPath("..", "..", "status", "/")
+}
+
+// GetStatus returns the status of the Netbox instance
+func GetStatus(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+) (Status, error) {
+	requestUrl := urlStatus(requestClient.NetboxURL)
+	return get[Status](ctx, requestClient, endpointStatus, requestUrl.String())
+}
+
+// Check verifies that the Netbox instance is reachable, that a supported
+// version of netbox-plugin-dns is installed, and that the token can view
+// zones and records. Every problem found is returned.
+func Check(ctx context.Context, requestClient *APIRequestClient) error {
+	status, err := GetStatus(ctx, requestClient)
+	if err != nil {
+		return fmt.Errorf("could not get netbox status: %w", err)
+	}
+	var errs []error
+	version, ok := status.Plugins[pluginPackage]
+	switch {
+	case !ok:
+		errs = append(errs, fmt.Errorf(
+			"netbox-plugin-dns is not installed on netbox %s",
+			status.NetboxVersion,
+		))
+	case compareVersions(version, MinimumPluginVersion) < 0:
+		errs = append(errs, fmt.Errorf(
+			"netbox-plugin-dns %s is older than the minimum supported version %s",
+			version,
+			MinimumPluginVersion,
+		))
+	}
+	checks := []struct {
+		endpoint   string
+		url        *url.URL
+		permission string
+	}{
+		{endpointZones, urlZones(requestClient.NetboxURL), "netbox_dns.view_zone"},
+		{endpointRecords, urlRecords(requestClient.NetboxURL), "netbox_dns.view_record"},
+	}
+	for _, check := range checks {
+		check.url.RawQuery = "limit=1"
+		response, err := doGet(ctx, requestClient, check.endpoint, check.url.String())
+		if err != nil {
+			errs = append(errs, fmt.Errorf(
+				"could not list %s: %w",
+				check.endpoint,
+				err,
+			))
+			continue
+		}
+		discardResponse(response)
+		switch response.StatusCode {
+		case http.StatusOK:
+		case http.StatusForbidden, http.StatusUnauthorized:
+			errs = append(errs, fmt.Errorf(
+				"token is not permitted to list %s; it requires %q",
+				check.endpoint,
+				check.permission,
+			))
+		case http.StatusNotFound:
+			errs = append(errs, fmt.Errorf(
+				"%s endpoint not found at %q; check the url",
+				check.endpoint,
+				check.url.Path,
+			))
+		default:
+			errs = append(errs, fmt.Errorf(
+				"could not list %s: %w",
+				check.endpoint,
+				responseError(response),
+			))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compareVersions compares two dotted version strings numerically, ignoring
+// any pre-release or build suffix, and returns -1, 0, or 1
+func compareVersions(a string, b string) int {
+	aParts := versionParts(a)
+	bParts := versionParts(b)
+	for i := range max(len(aParts), len(bParts)) {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		switch {
+		case aPart < bPart:
+			return -1
+		case aPart > bPart:
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	fields := strings.Split(version, ".")
+	out := make([]int, 0, len(fields))
+	for _, field := range fields {
+		digits := strings.IndexFunc(field, func(r rune) bool {
+			return r < '0' || r > '9'
+		})
+		if digits >= 0 {
+			field = field[:digits]
+		}
+		part, _ := strconv.Atoi(field)
+		out = append(out, part)
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package netbox
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.3.0", MinimumPluginVersion, 1},
+		{"0.22.8", MinimumPluginVersion, 0},
+		{"0.22.7", MinimumPluginVersion, -1},
+		{"0.22", MinimumPluginVersion, -1},
+		{"v1.4.0-beta1", "1.4.0", 0},
+		{"0.22.10", "0.22.8", 1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q): expected %d, got %d", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
+
+func newStatusClient(
+	t *testing.T,
+	pluginVersion string,
+	recordStatus int,
+) *APIRequestClient {
+	requestClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/api/status/"):
+			plugins := "{}"
+			if pluginVersion != "" {
+				plugins = `{"netbox_dns": "` + pluginVersion + `"}`
+			}
+			w.Write([]byte(`{"netbox-version": "4.3.0", "plugins": ` + plugins + `}`))
+		case strings.HasSuffix(r.URL.Path, "/records/"):
+			w.WriteHeader(recordStatus)
+			w.Write([]byte(`{"count": 0, "results": []}`))
+		default:
+			w.Write([]byte(`{"count": 0, "results": []}`))
+		}
+	})
+	requestClient.NetboxURL = requestClient.NetboxURL.JoinPath(
+		"api",
+		"plugins",
+		"netbox-dns",
+	)
+	return requestClient
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		pluginVersion string
+		recordStatus  int
+		wantErr       string
+	}{
+		{"supported", "1.3.0", http.StatusOK, ""},
+		{"plugin missing", "", http.StatusOK, "not installed"},
+		{"plugin too old", "0.21.0", http.StatusOK, "older than the minimum"},
+		{"missing permission", "1.3.0", http.StatusForbidden, "netbox_dns.view_record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestClient := newStatusClient(t, tt.pluginVersion, tt.recordStatus)
+			err := Check(context.Background(), requestClient)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("expected error containing %q, got none", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
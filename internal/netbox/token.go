@@ -0,0 +1,85 @@
+package netbox
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTokenFileInterval time.Duration = time.Second * 5
+
+// TokenSource provides the API token for each request, for tokens that can
+// change while the plugin is running
+type TokenSource interface {
+	Token() string
+}
+
+// FileToken is a TokenSource that reads the API token from a file, and reads
+// it again when the file changes, such as when a Kubernetes secret is rotated
+type FileToken struct {
+	path string
+	// interval is the minimum time between checks for changes to the file
+	interval time.Duration
+
+	mutex   sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+// NewFileToken reads the API token from the file at path
+func NewFileToken(path string) (*FileToken, error) {
+	fileToken := &FileToken{
+		path:     path,
+		interval: defaultTokenFileInterval,
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := fileToken.load(info); err != nil {
+		return nil, err
+	}
+	return fileToken, nil
+}
+
+// Token returns the token read from the file. If the file has changed since
+// it was last read but cannot be read again, the previous token is returned.
+func (fileToken *FileToken) Token() string {
+	fileToken.mutex.Lock()
+	defer fileToken.mutex.Unlock()
+	if time.Since(fileToken.checked) < fileToken.interval {
+		return fileToken.token
+	}
+	fileToken.checked = time.Now()
+	info, err := os.Stat(fileToken.path)
+	if err != nil {
+		return fileToken.token
+	}
+	if info.ModTime().Equal(fileToken.modTime) && info.Size() == fileToken.size {
+		return fileToken.token
+	}
+	fileToken.load(info)
+	return fileToken.token
+}
+
+// load reads the token from the file. The caller must hold the mutex, or be
+// the only user of fileToken.
+func (fileToken *FileToken) load(info os.FileInfo) error {
+	content, err := os.ReadFile(fileToken.path)
+	if err != nil {
+		return err
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return fmt.Errorf("token file %q is empty", fileToken.path)
+	}
+	fileToken.token = token
+	fileToken.modTime = info.ModTime()
+	fileToken.size = info.Size()
+	fileToken.checked = time.Now()
+	return nil
+}
@@ -0,0 +1,51 @@
+package netbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fileToken, err := NewFileToken(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	fileToken.interval = 0
+	if token := fileToken.Token(); token != "first" {
+		t.Errorf("expected token %q, got %q", "first", token)
+	}
+
+	if err := os.WriteFile(path, []byte("second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if token := fileToken.Token(); token != "second" {
+		t.Errorf("expected rotated token %q, got %q", "second", token)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if token := fileToken.Token(); token != "second" {
+		t.Errorf("expected previous token %q, got %q", "second", token)
+	}
+}
+
+func TestFileTokenEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileToken(path); err == nil {
+		t.Error("expected error for empty token file, got none")
+	}
+}
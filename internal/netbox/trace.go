@@ -0,0 +1,25 @@
+package netbox
+
+import (
+	"context"
+
+	ot "github.com/opentracing/opentracing-go"
+)
+
+// StartSpan starts a child of the span carried by ctx, as set by the *trace*
+// plugin. If ctx carries no span, a no-op span is returned so that callers do
+// not need to check whether tracing is enabled.
+func StartSpan(
+	ctx context.Context,
+	operationName string,
+) (ot.Span, context.Context) {
+	parent := ot.SpanFromContext(ctx)
+	if parent == nil {
+		return ot.NoopTracer{}.StartSpan(operationName), ctx
+	}
+	span := parent.Tracer().StartSpan(
+		operationName,
+		ot.ChildOf(parent.Context()),
+	)
+	return span, ot.ContextWithSpan(ctx, span)
+}
@@ -0,0 +1,44 @@
+package netbox
+
+import (
+	"context"
+	"net/url"
+)
+
+type View struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+const endpointViews string = "views"
+
+func urlViews(netboxurl *url.URL) *url.URL {
+	return netboxurl.JoinPath("views", "/")
+}
+
+// GetViews returns every view
+func GetViews(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+) ([]View, error) {
+	span, ctx := StartSpan(ctx, "netbox.GetViews")
+	defer span.Finish()
+	requestUrl := urlViews(requestClient.NetboxURL)
+	return getMany[View](ctx, requestClient, endpointViews, requestUrl.String())
+}
+
+// CreateView creates a view named name and returns it
+func CreateView(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	name string,
+) (View, error) {
+	requestUrl := urlViews(requestClient.NetboxURL)
+	return create[View](
+		ctx,
+		requestClient,
+		endpointViews,
+		requestUrl.String(),
+		View{Name: name},
+	)
+}
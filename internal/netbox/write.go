@@ -0,0 +1,167 @@
+package netbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// maxErrorDetail is the length of a failed write's response body kept in its
+// ResponseError
+const maxErrorDetail int64 = 1024
+
+// doWrite sends a request with body encoded as JSON. Unlike reads, writes are
+// not retried or failed over to another endpoint, as repeating one that
+// reached Netbox could apply it twice.
+func doWrite(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	method string,
+	endpoint string,
+	url string,
+	body any,
+) (*http.Response, error) {
+	span, ctx := StartSpan(ctx, "netbox."+strings.ToLower(method)+" "+endpoint)
+	defer span.Finish()
+	ext.HTTPMethod.Set(span, method)
+	ext.HTTPUrl.Set(span, url)
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", requestClient.authorization())
+	request.Header.Set("User-Agent", requestClient.UserAgent)
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	response, err := requestClient.Client.Do(request)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(response.StatusCode)
+		ext.HTTPStatusCode.Set(span, uint16(response.StatusCode))
+	} else {
+		ext.LogError(span, err)
+	}
+	requestCount.WithLabelValues(endpoint, code).Inc()
+	requestDuration.WithLabelValues(endpoint, code).Observe(
+		time.Since(start).Seconds(),
+	)
+	return response, err
+}
+
+// writeError returns a ResponseError if response does not have status want,
+// with the start of the body that explains why
+func writeError(response *http.Response, want int) error {
+	if response.StatusCode == want {
+		return nil
+	}
+	detail, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorDetail))
+	return &ResponseError{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Detail:     strings.TrimSpace(string(detail)),
+	}
+}
+
+// send writes body to url with method and decodes the object returned
+func send[T APIResultModel](
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	method string,
+	endpoint string,
+	url string,
+	body any,
+	want int,
+) (T, error) {
+	var out T
+	response, err := doWrite(ctx, requestClient, method, endpoint, url, body)
+	if err != nil {
+		return out, err
+	}
+	defer response.Body.Close()
+	if err := writeError(response, want); err != nil {
+		return out, err
+	}
+	decoder := json.NewDecoder(response.Body)
+	if err := decoder.Decode(&out); err != nil {
+		return out, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return out, nil
+}
+
+func create[T APIResultModel](
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	endpoint string,
+	url string,
+	body any,
+) (T, error) {
+	return send[T](
+		ctx,
+		requestClient,
+		http.MethodPost,
+		endpoint,
+		url,
+		body,
+		http.StatusCreated,
+	)
+}
+
+func update[T APIResultModel](
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	endpoint string,
+	url string,
+	body any,
+) (T, error) {
+	return send[T](
+		ctx,
+		requestClient,
+		http.MethodPatch,
+		endpoint,
+		url,
+		body,
+		http.StatusOK,
+	)
+}
+
+func remove(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	endpoint string,
+	url string,
+) error {
+	response, err := doWrite(
+		ctx,
+		requestClient,
+		http.MethodDelete,
+		endpoint,
+		url,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	defer discardResponse(response)
+	return writeError(response, http.StatusNoContent)
+}
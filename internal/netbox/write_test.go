@@ -0,0 +1,87 @@
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCreateRecord(t *testing.T) {
+	ttl := uint32(300)
+	requestClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/records/" {
+			t.Errorf("expected POST /records/, got %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Token sometoken" {
+			t.Errorf("expected token authorization, got %q", got)
+		}
+		var body RecordRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("expected JSON body, got %v", err)
+		}
+		if body.Zone != 1 || body.Name != "www" || *body.TTL != ttl {
+			t.Errorf("unexpected body %+v", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 7, "name": "www", "type": "A", "value": "10.0.0.1", "ttl": 300}`))
+	})
+	record, err := CreateRecord(context.Background(), requestClient, &RecordRequest{
+		Zone:  1,
+		Name:  "www",
+		Type:  "A",
+		Value: "10.0.0.1",
+		TTL:   &ttl,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if record.ID != 7 {
+		t.Errorf("expected record 7, got %d", record.ID)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	var requests atomic.Int32
+	requestClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"value": ["Enter a valid IPv4 address."]}`))
+	})
+	requestClient.Retry = RetryPolicy{Attempts: 2, Backoff: time.Millisecond}
+	_, err := CreateRecord(context.Background(), requestClient, &RecordRequest{
+		Zone:  1,
+		Name:  "www",
+		Type:  "A",
+		Value: "10.0.0",
+	})
+	var responseErr *ResponseError
+	if !errors.As(err, &responseErr) {
+		t.Fatalf("expected ResponseError, got %v", err)
+	}
+	if responseErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", responseErr.StatusCode)
+	}
+	if !strings.Contains(err.Error(), "Enter a valid IPv4 address.") {
+		t.Errorf("expected error to include the response body, got %q", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected writes not to be retried, got %d requests", got)
+	}
+}
+
+func TestDeleteRecord(t *testing.T) {
+	requestClient := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/records/7/" {
+			t.Errorf("expected DELETE /records/7/, got %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if err := DeleteRecord(context.Background(), requestClient, 7); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
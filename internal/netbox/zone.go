@@ -1,6 +1,7 @@
 package netbox
 
 import (
+	"context"
 	"net/url"
 	"strconv"
 )
@@ -10,12 +11,23 @@ type Zone struct {
 	ID          int        `json:"id"`
 	Name        string     `json:"name"`
 	NameServers []SOAMName `json:"nameservers"`
+	SOAExpire   uint32     `json:"soa_expire"`
+	SOAMinimum  uint32     `json:"soa_minimum"`
+	SOAMName    SOAMName   `json:"soa_mname"`
+	SOARefresh  uint32     `json:"soa_refresh"`
+	SOARetry    uint32     `json:"soa_retry"`
+	SOARName    string     `json:"soa_rname"`
+	SOASerial   uint32     `json:"soa_serial"`
+	SOATTL      uint32     `json:"soa_ttl"`
+	View        View       `json:"view"`
 }
 
 type SOAMName struct {
 	Name string `json:"name"`
 }
 
+const endpointZones string = "zones"
+
 func urlZones(netboxurl *url.URL) *url.URL {
 	return netboxurl.JoinPath("zones", "/")
 }
@@ -24,11 +36,72 @@ func urlZoneID(netboxurl *url.URL, id int) *url.URL {
 	return netboxurl.JoinPath("zones", "/", strconv.Itoa(id), "/")
 }
 
-func GetZones(requestClient *APIRequestClient) ([]Zone, error) {
+func GetZones(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+) ([]Zone, error) {
+	span, ctx := StartSpan(ctx, "netbox.GetZones")
+	defer span.Finish()
 	requestUrl := urlZones(requestClient.NetboxURL)
-	zones, err := getMany[Zone](requestClient, requestUrl.String())
+	zones, err := getMany[Zone](
+		ctx,
+		requestClient,
+		endpointZones,
+		requestUrl.String(),
+	)
 	if err != nil {
 		return nil, err
 	}
 	return zones, nil
 }
+
+// ZoneRequest is the body of a request creating or updating a zone. The view,
+// nameservers, and SOA MNAME are referenced by ID.
+type ZoneRequest struct {
+	Name          string `json:"name"`
+	View          int    `json:"view"`
+	NameServers   []int  `json:"nameservers"`
+	DefaultTTL    uint32 `json:"default_ttl"`
+	SOAExpire     uint32 `json:"soa_expire"`
+	SOAMinimum    uint32 `json:"soa_minimum"`
+	SOAMName      int    `json:"soa_mname"`
+	SOARefresh    uint32 `json:"soa_refresh"`
+	SOARetry      uint32 `json:"soa_retry"`
+	SOARName      string `json:"soa_rname"`
+	SOASerial     uint32 `json:"soa_serial"`
+	SOASerialAuto bool   `json:"soa_serial_auto"`
+	SOATTL        uint32 `json:"soa_ttl"`
+}
+
+// CreateZone creates a zone and returns it
+func CreateZone(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	zone *ZoneRequest,
+) (Zone, error) {
+	requestUrl := urlZones(requestClient.NetboxURL)
+	return create[Zone](
+		ctx,
+		requestClient,
+		endpointZones,
+		requestUrl.String(),
+		zone,
+	)
+}
+
+// UpdateZone replaces the fields of the zone with id and returns it
+func UpdateZone(
+	ctx context.Context,
+	requestClient *APIRequestClient,
+	id int,
+	zone *ZoneRequest,
+) (Zone, error) {
+	requestUrl := urlZoneID(requestClient.NetboxURL, id)
+	return update[Zone](
+		ctx,
+		requestClient,
+		endpointZones,
+		requestUrl.String(),
+		zone,
+	)
+}
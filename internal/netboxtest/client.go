@@ -0,0 +1,40 @@
+package netboxtest
+
+import (
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+)
+
+// Token is the API token accepted by the Server started by NewClient
+const Token string = "sometoken"
+
+// NewClient starts a Server populated with the fixtures in .testing/init, closed
+// when t completes, and returns it with an API client authenticated against it
+func NewClient(t testing.TB) (*Server, *netbox.APIRequestClient) {
+	t.Helper()
+	fixtures, err := LoadFixtures(fixturesDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(fixtures, Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server, &netbox.APIRequestClient{
+		Client:    &http.Client{},
+		NetboxURL: server.APIURL(),
+		Token:     Token,
+	}
+}
+
+// fixturesDir returns the .testing/init directory of the repository, so that it
+// is found from the tests of any package
+func fixturesDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", ".testing", "init")
+}
@@ -0,0 +1,68 @@
+package netboxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FixtureName is an object referenced by name, such as a view or nameserver
+type FixtureName struct {
+	Name string `json:"name"`
+}
+
+// FixtureZone is a zone as it is POSTed to the Netbox API
+type FixtureZone struct {
+	Name        string        `json:"name"`
+	View        FixtureName   `json:"view"`
+	NameServers []FixtureName `json:"nameservers"`
+	DefaultTTL  uint32        `json:"default_ttl"`
+	SOAExpire   uint32        `json:"soa_expire"`
+	SOAMinimum  uint32        `json:"soa_minimum"`
+	SOAMName    FixtureName   `json:"soa_mname"`
+	SOATTL      uint32        `json:"soa_ttl"`
+	SOARefresh  uint32        `json:"soa_refresh"`
+	SOARetry    uint32        `json:"soa_retry"`
+	SOARName    string        `json:"soa_rname"`
+	SOASerial   uint32        `json:"soa_serial"`
+}
+
+// FixtureRecord is a record as it is POSTed to the Netbox API
+type FixtureRecord struct {
+	Zone  FixtureName `json:"zone"`
+	Type  string      `json:"type"`
+	Name  string      `json:"name"`
+	Value string      `json:"value"`
+	TTL   *uint32     `json:"ttl"`
+}
+
+// Fixtures are the zones and records a Server is populated with
+type Fixtures struct {
+	Zones   []FixtureZone
+	Records []FixtureRecord
+}
+
+// LoadFixtures reads zones.json and records.json from dir, in the format used
+// by .testing/init to populate a Netbox instance
+func LoadFixtures(dir string) (*Fixtures, error) {
+	fixtures := &Fixtures{}
+	if err := readFixture(dir, "zones.json", &fixtures.Zones); err != nil {
+		return nil, err
+	}
+	if err := readFixture(dir, "records.json", &fixtures.Records); err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+func readFixture(dir string, name string, out any) error {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("could not decode %q: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,516 @@
+// Package netboxtest provides an in-process fake of the netbox-plugin-dns API
+// so that the plugin can be tested without a Netbox instance
+package netboxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultPageSize is the number of results returned per page when a
+	// request has no limit, as in Netbox
+	DefaultPageSize int = 50
+	// MaxPageSize is the largest number of results returned per page, as in
+	// Netbox
+	MaxPageSize int = 1000
+
+	apiPath string = "/api/plugins/netbox-dns/"
+)
+
+// Server is a fake Netbox instance serving the views, nameservers, zones, and
+// records endpoints of netbox-plugin-dns. Like Netbox, it creates the SOA and
+// NS records of every zone, and PTR records for the A and AAAA records of the
+// fixtures in a reverse zone it serves. Zones can be created and updated, and
+// records created, updated, and deleted.
+type Server struct {
+	*httptest.Server
+
+	// NetboxVersion and PluginVersion are reported by the status endpoint
+	NetboxVersion string
+	PluginVersion string
+	// PageSize is the number of results returned per page when a request has
+	// no limit
+	PageSize int
+
+	token    string
+	requests atomic.Int64
+
+	mutex        sync.RWMutex
+	views        []objectRef
+	nameServers  []objectRef
+	zones        []zone
+	records      []record
+	nextRecordID int
+}
+
+type objectRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type zone struct {
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	View        objectRef   `json:"view"`
+	NameServers []objectRef `json:"nameservers"`
+	Status      string      `json:"status"`
+	DefaultTTL  uint32      `json:"default_ttl"`
+	SOATTL      uint32      `json:"soa_ttl"`
+	SOAMName    objectRef   `json:"soa_mname"`
+	SOARName    string      `json:"soa_rname"`
+	SOASerial   uint32      `json:"soa_serial"`
+	SOARefresh  uint32      `json:"soa_refresh"`
+	SOARetry    uint32      `json:"soa_retry"`
+	SOAExpire   uint32      `json:"soa_expire"`
+	SOAMinimum  uint32      `json:"soa_minimum"`
+}
+
+// nestedZone is the zone embedded in a record, which like in Netbox does not
+// include the default TTL
+type nestedZone struct {
+	ID     int       `json:"id"`
+	Name   string    `json:"name"`
+	View   objectRef `json:"view"`
+	Status string    `json:"status"`
+}
+
+type record struct {
+	ID      int        `json:"id"`
+	Zone    nestedZone `json:"zone"`
+	Name    string     `json:"name"`
+	FQDN    string     `json:"fqdn"`
+	Type    string     `json:"type"`
+	Value   string     `json:"value"`
+	TTL     *uint32    `json:"ttl"`
+	Status  string     `json:"status"`
+	Managed bool       `json:"managed"`
+}
+
+// NewServer starts a Server populated with fixtures that accepts token. Tokens
+// prefixed with "nbt_" are accepted with the Bearer scheme, and others with
+// the Token scheme.
+func NewServer(fixtures *Fixtures, token string) (*Server, error) {
+	server := &Server{
+		NetboxVersion: "4.2.0",
+		PluginVersion: "1.2.0",
+		PageSize:      DefaultPageSize,
+		token:         token,
+	}
+	if err := server.populate(fixtures); err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status/", server.handleStatus)
+	mux.Handle(
+		"GET "+apiPath+"zones/",
+		server.authenticate(server.handleZones),
+	)
+	mux.Handle(
+		"GET "+apiPath+"zones/{id}/",
+		server.authenticate(server.handleZone),
+	)
+	mux.Handle(
+		"GET "+apiPath+"records/",
+		server.authenticate(server.handleRecords),
+	)
+	mux.Handle(
+		"GET "+apiPath+"views/",
+		server.authenticate(server.handleViews),
+	)
+	mux.Handle(
+		"GET "+apiPath+"nameservers/",
+		server.authenticate(server.handleNameServers),
+	)
+	mux.Handle(
+		"POST "+apiPath+"views/",
+		server.authenticate(server.handleCreateView),
+	)
+	mux.Handle(
+		"POST "+apiPath+"nameservers/",
+		server.authenticate(server.handleCreateNameServer),
+	)
+	mux.Handle(
+		"POST "+apiPath+"zones/",
+		server.authenticate(server.handleCreateZone),
+	)
+	mux.Handle(
+		"PATCH "+apiPath+"zones/{id}/",
+		server.authenticate(server.handleUpdateZone),
+	)
+	mux.Handle(
+		"POST "+apiPath+"records/",
+		server.authenticate(server.handleCreateRecord),
+	)
+	mux.Handle(
+		"PATCH "+apiPath+"records/{id}/",
+		server.authenticate(server.handleUpdateRecord),
+	)
+	mux.Handle(
+		"DELETE "+apiPath+"records/{id}/",
+		server.authenticate(server.handleDeleteRecord),
+	)
+	server.Server = httptest.NewServer(
+		http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			server.requests.Add(1)
+			if request.Method == http.MethodGet {
+				server.mutex.RLock()
+				defer server.mutex.RUnlock()
+			} else {
+				server.mutex.Lock()
+				defer server.mutex.Unlock()
+			}
+			mux.ServeHTTP(writer, request)
+		}),
+	)
+	return server, nil
+}
+
+// APIURL returns the URL of the netbox-plugin-dns API
+func (server *Server) APIURL() *url.URL {
+	apiURL, _ := url.Parse(server.URL + apiPath)
+	return apiURL
+}
+
+// Requests returns the number of requests the server has received
+func (server *Server) Requests() int64 {
+	return server.requests.Load()
+}
+
+func (server *Server) populate(fixtures *Fixtures) error {
+	views := map[string]int{}
+	nameServers := map[string]int{}
+	ref := func(ids map[string]int, name string) objectRef {
+		if _, ok := ids[name]; !ok {
+			ids[name] = len(ids) + 1
+		}
+		return objectRef{ID: ids[name], Name: name}
+	}
+
+	for k, fixture := range fixtures.Zones {
+		newZone := zone{
+			ID:         k + 1,
+			Name:       strings.TrimSuffix(fixture.Name, "."),
+			View:       ref(views, fixture.View.Name),
+			Status:     "active",
+			DefaultTTL: fixture.DefaultTTL,
+			SOATTL:     fixture.SOATTL,
+			SOAMName:   ref(nameServers, fixture.SOAMName.Name),
+			SOARName:   fixture.SOARName,
+			SOASerial:  fixture.SOASerial,
+			SOARefresh: fixture.SOARefresh,
+			SOARetry:   fixture.SOARetry,
+			SOAExpire:  fixture.SOAExpire,
+			SOAMinimum: fixture.SOAMinimum,
+		}
+		for _, nameServer := range fixture.NameServers {
+			newZone.NameServers = append(
+				newZone.NameServers,
+				ref(nameServers, nameServer.Name),
+			)
+		}
+		server.zones = append(server.zones, newZone)
+	}
+	zonesByName := make(map[string]*zone, len(server.zones))
+	for k := range server.zones {
+		zonesByName[strings.ToLower(server.zones[k].Name)] = &server.zones[k]
+	}
+
+	server.views = refs(views)
+	server.nameServers = refs(nameServers)
+
+	for _, origin := range server.zones {
+		server.addZoneRecords(origin)
+	}
+
+	for _, fixture := range fixtures.Records {
+		recordZone, ok := zonesByName[netbox.NormalizeName(fixture.Zone.Name)]
+		if !ok {
+			return fmt.Errorf(
+				"record [%s] %q references unknown zone %q",
+				fixture.Type,
+				fixture.Name,
+				fixture.Zone.Name,
+			)
+		}
+		server.addRecord(
+			*recordZone,
+			fixture.Name,
+			fixture.Type,
+			fixture.Value,
+			fixture.TTL,
+			false,
+		)
+	}
+
+	// create the PTR records of address records, in the closest enclosing
+	// reverse zone
+	for _, address := range slices.Clone(server.records) {
+		if address.Type != "A" && address.Type != "AAAA" {
+			continue
+		}
+		reverse, err := dns.ReverseAddr(address.Value)
+		if err != nil {
+			return fmt.Errorf(
+				"record [%s] %q has an invalid address: %w",
+				address.Type,
+				address.FQDN,
+				err,
+			)
+		}
+		reverse = netbox.NormalizeName(reverse)
+		for _, offset := range dns.Split(reverse) {
+			reverseZone, ok := zonesByName[reverse[offset:]]
+			if !ok {
+				continue
+			}
+			name := strings.TrimSuffix(reverse[:offset], ".")
+			if name == "" {
+				name = "@"
+			}
+			server.addRecord(*reverseZone, name, "PTR", address.FQDN, nil, true)
+			break
+		}
+	}
+	return nil
+}
+
+// refs returns the objects named in ids, ordered by ID
+func refs(ids map[string]int) []objectRef {
+	out := make([]objectRef, 0, len(ids))
+	for name, id := range ids {
+		out = append(out, objectRef{ID: id, Name: name})
+	}
+	slices.SortFunc(out, func(a, b objectRef) int { return a.ID - b.ID })
+	return out
+}
+
+// addZoneRecords adds the managed SOA and NS records of origin
+func (server *Server) addZoneRecords(origin zone) {
+	soaTTL := origin.SOATTL
+	server.addRecord(origin, "@", "SOA", fmt.Sprintf(
+		"%s %s %d %d %d %d %d",
+		dns.Fqdn(origin.SOAMName.Name),
+		dns.Fqdn(origin.SOARName),
+		origin.SOASerial,
+		origin.SOARefresh,
+		origin.SOARetry,
+		origin.SOAExpire,
+		origin.SOAMinimum,
+	), &soaTTL, true)
+	for _, nameServer := range origin.NameServers {
+		server.addRecord(origin, "@", "NS", dns.Fqdn(nameServer.Name), nil, true)
+	}
+}
+
+// addRecord adds a record to recordZone and returns it
+func (server *Server) addRecord(
+	recordZone zone,
+	name string,
+	recordType string,
+	value string,
+	ttl *uint32,
+	managed bool,
+) record {
+	server.nextRecordID++
+	newRecord := newRecord(recordZone, name, recordType, value, ttl, managed)
+	newRecord.ID = server.nextRecordID
+	server.records = append(server.records, newRecord)
+	return newRecord
+}
+
+// newRecord returns a record of recordZone without an ID
+func newRecord(
+	recordZone zone,
+	name string,
+	recordType string,
+	value string,
+	ttl *uint32,
+	managed bool,
+) record {
+	fqdn := dns.Fqdn(recordZone.Name)
+	if name != "@" {
+		fqdn = dns.Fqdn(name + "." + recordZone.Name)
+	}
+	return record{
+		Zone: nestedZone{
+			ID:     recordZone.ID,
+			Name:   recordZone.Name,
+			View:   recordZone.View,
+			Status: recordZone.Status,
+		},
+		Name:    name,
+		FQDN:    fqdn,
+		Type:    recordType,
+		Value:   value,
+		TTL:     ttl,
+		Status:  "active",
+		Managed: managed,
+	}
+}
+
+// authenticate rejects requests without the server's token, as Netbox does
+func (server *Server) authenticate(next http.HandlerFunc) http.Handler {
+	scheme := "Token"
+	if strings.HasPrefix(server.token, "nbt_") {
+		scheme = "Bearer"
+	}
+	want := scheme + " " + server.token
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		authorization := request.Header.Get("Authorization")
+		switch {
+		case authorization == "":
+			writeError(
+				writer,
+				http.StatusForbidden,
+				"Authentication credentials were not provided.",
+			)
+		case authorization != want:
+			writeError(writer, http.StatusForbidden, "Invalid token")
+		default:
+			next(writer, request)
+		}
+	})
+}
+
+func (server *Server) handleStatus(
+	writer http.ResponseWriter,
+	_ *http.Request,
+) {
+	writeJSON(writer, map[string]any{
+		"netbox-version": server.NetboxVersion,
+		"plugins": map[string]string{
+			"netbox_dns": server.PluginVersion,
+		},
+	})
+}
+
+func (server *Server) handleZones(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	names := request.URL.Query()["name"]
+	results := make([]zone, 0, len(server.zones))
+	for _, candidate := range server.zones {
+		if len(names) == 0 || containsName(names, candidate.Name) {
+			results = append(results, candidate)
+		}
+	}
+	writePage(writer, request, results, server.PageSize)
+}
+
+func (server *Server) handleZone(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	id, err := strconv.Atoi(request.PathValue("id"))
+	if err == nil && id > 0 && id <= len(server.zones) {
+		writeJSON(writer, server.zones[id-1])
+		return
+	}
+	writeError(writer, http.StatusNotFound, "No Zone matches the given query.")
+}
+
+func (server *Server) handleRecords(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	query := request.URL.Query()
+	zoneIDs := query["zone_id"]
+	results := make([]record, 0)
+	for _, record := range server.records {
+		if len(query["fqdn"]) != 0 && !containsName(query["fqdn"], record.FQDN) {
+			continue
+		}
+		if len(query["name"]) != 0 && !slices.Contains(query["name"], record.Name) {
+			continue
+		}
+		if len(query["type"]) != 0 && !slices.Contains(query["type"], record.Type) {
+			continue
+		}
+		if len(zoneIDs) != 0 &&
+			!slices.Contains(zoneIDs, strconv.Itoa(record.Zone.ID)) {
+			continue
+		}
+		results = append(results, record)
+	}
+	writePage(writer, request, results, server.PageSize)
+}
+
+// writePage writes the page of results selected by the limit and offset
+// parameters, linking to the next page if there is one
+func writePage[T any](
+	writer http.ResponseWriter,
+	request *http.Request,
+	results []T,
+	pageSize int,
+) {
+	query := request.URL.Query()
+	limit := pageSize
+	if value := query.Get("limit"); value != "" {
+		limit, _ = strconv.Atoi(value)
+	}
+	if limit <= 0 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	offset = max(0, min(offset, len(results)))
+	end := min(offset+limit, len(results))
+
+	var next *string
+	if end < len(results) {
+		nextURL := *request.URL
+		nextURL.Scheme = "http"
+		nextURL.Host = request.Host
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(end))
+		nextURL.RawQuery = query.Encode()
+		link := nextURL.String()
+		next = &link
+	}
+	writeJSON(writer, map[string]any{
+		"count":    len(results),
+		"next":     next,
+		"previous": nil,
+		"results":  results[offset:end],
+	})
+}
+
+func writeJSON(writer http.ResponseWriter, body any) {
+	writeJSONStatus(writer, http.StatusOK, body)
+}
+
+func writeJSONStatus(writer http.ResponseWriter, status int, body any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(body)
+}
+
+func writeError(writer http.ResponseWriter, status int, detail string) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(map[string]string{"detail": detail})
+}
+
+func containsName(names []string, name string) bool {
+	name = netbox.NormalizeName(name)
+	for _, candidate := range names {
+		if netbox.NormalizeName(candidate) == name {
+			return true
+		}
+	}
+	return false
+}
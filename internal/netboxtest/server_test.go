@@ -0,0 +1,227 @@
+package netboxtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+)
+
+const testToken string = "sometoken"
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	fixtures, err := LoadFixtures("../../.testing/init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(fixtures, testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClient(server *Server, token string) *netbox.APIRequestClient {
+	return &netbox.APIRequestClient{
+		Client:    server.Client(),
+		NetboxURL: server.APIURL(),
+		Token:     token,
+	}
+}
+
+func TestServerPagination(t *testing.T) {
+	server := newTestServer(t)
+	server.PageSize = 2
+	zones, err := netbox.GetZones(
+		context.Background(),
+		newTestClient(server, testToken),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(zones) != len(server.zones) {
+		t.Errorf("expected %d zones, got %d", len(server.zones), len(zones))
+	}
+	// one request per page
+	wantRequests := int64((len(server.zones) + 1) / 2)
+	if server.Requests() != wantRequests {
+		t.Errorf("expected %d requests, got %d", wantRequests, server.Requests())
+	}
+}
+
+func TestServerRecordFilters(t *testing.T) {
+	server := newTestServer(t)
+	requestClient := newTestClient(server, testToken)
+	zones, err := netbox.GetZones(context.Background(), requestClient)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	tests := []struct {
+		name  string
+		query netbox.RecordQuery
+		want  int
+	}{
+		{
+			"fqdn",
+			netbox.RecordQuery{FQDN: []string{"dns01.example.com"}},
+			2,
+		},
+		{
+			"fqdn and type",
+			netbox.RecordQuery{
+				FQDN: []string{"dns01.example.com", "dns02.example.com."},
+				Type: []string{"A"},
+			},
+			2,
+		},
+		{
+			"origin in zone",
+			netbox.RecordQuery{
+				Name: "@",
+				Type: []string{"SOA", "NS"},
+				Zone: &zones[0],
+			},
+			3,
+		},
+		{
+			"ptr",
+			netbox.RecordQuery{FQDN: []string{"10.0.0.10.in-addr.arpa"}},
+			1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := netbox.GetRecordsQuery(
+				context.Background(),
+				requestClient,
+				&tt.query,
+			)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(records) != tt.want {
+				t.Errorf("expected %d records, got %d", tt.want, len(records))
+			}
+			for _, record := range records {
+				if record.TTL == nil {
+					t.Errorf("expected ttl to be resolved for record %d", record.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestServerAuthentication(t *testing.T) {
+	server := newTestServer(t)
+	_, err := netbox.GetZones(
+		context.Background(),
+		newTestClient(server, "noop"),
+	)
+	var responseErr *netbox.ResponseError
+	if !errors.As(err, &responseErr) {
+		t.Fatalf("expected response error, got %v", err)
+	}
+	if responseErr.StatusCode != http.StatusForbidden {
+		t.Errorf(
+			"expected status %d, got %d",
+			http.StatusForbidden,
+			responseErr.StatusCode,
+		)
+	}
+}
+
+func TestServerWrites(t *testing.T) {
+	server := newTestServer(t)
+	requestClient := newTestClient(server, testToken)
+	ctx := context.Background()
+
+	view, err := netbox.CreateView(ctx, requestClient, "imported")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	nameServer, err := netbox.CreateNameServer(ctx, requestClient, "ns1.example.net")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	created, err := netbox.CreateZone(ctx, requestClient, &netbox.ZoneRequest{
+		Name:        "example.net",
+		View:        view.ID,
+		NameServers: []int{nameServer.ID},
+		DefaultTTL:  3600,
+		SOAMName:    nameServer.ID,
+		SOARName:    "hostmaster.example.net",
+		SOASerial:   1,
+		SOATTL:      3600,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if created.View.Name != "imported" || len(created.NameServers) != 1 {
+		t.Errorf("expected zone in view with one nameserver, got %+v", created)
+	}
+
+	ttl := uint32(300)
+	record, err := netbox.CreateRecord(ctx, requestClient, &netbox.RecordRequest{
+		Zone:  created.ID,
+		Name:  "www",
+		Type:  "A",
+		Value: "10.0.0.1",
+		TTL:   &ttl,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if record.FQDN != "www.example.net." {
+		t.Errorf("expected record www.example.net., got %q", record.FQDN)
+	}
+	_, err = netbox.CreateRecord(ctx, requestClient, &netbox.RecordRequest{
+		Zone:  created.ID,
+		Name:  "bad",
+		Type:  "A",
+		Value: "10.0.0",
+	})
+	var responseErr *netbox.ResponseError
+	if !errors.As(err, &responseErr) ||
+		responseErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected invalid value to be rejected, got %v", err)
+	}
+
+	record, err = netbox.UpdateRecord(ctx, requestClient, record.ID, &netbox.RecordRequest{
+		Zone:  created.ID,
+		Name:  "www",
+		Type:  "A",
+		Value: "10.0.0.2",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	records, err := netbox.GetRecordsQuery(ctx, requestClient, &netbox.RecordQuery{
+		FQDN: []string{"www.example.net"},
+		Zone: &created,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(records) != 1 || records[0].Value != "10.0.0.2" ||
+		*records[0].TTL != created.DefaultTTL {
+		t.Errorf("expected updated record with default TTL, got %+v", records)
+	}
+
+	if err := netbox.DeleteRecord(ctx, requestClient, record.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	records, err = netbox.GetRecordsQuery(ctx, requestClient, &netbox.RecordQuery{
+		Zone: &created,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// only the managed SOA and NS records remain
+	if len(records) != 2 {
+		t.Errorf("expected 2 records, got %+v", records)
+	}
+}
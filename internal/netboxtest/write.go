@@ -0,0 +1,394 @@
+package netboxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+// zoneWrite is the body of a request creating or updating a zone, which
+// references the view, nameservers, and SOA MNAME by ID
+type zoneWrite struct {
+	Name        string `json:"name"`
+	View        int    `json:"view"`
+	NameServers []int  `json:"nameservers"`
+	DefaultTTL  uint32 `json:"default_ttl"`
+	SOATTL      uint32 `json:"soa_ttl"`
+	SOAMName    int    `json:"soa_mname"`
+	SOARName    string `json:"soa_rname"`
+	SOASerial   uint32 `json:"soa_serial"`
+	SOARefresh  uint32 `json:"soa_refresh"`
+	SOARetry    uint32 `json:"soa_retry"`
+	SOAExpire   uint32 `json:"soa_expire"`
+	SOAMinimum  uint32 `json:"soa_minimum"`
+}
+
+// recordWrite is the body of a request creating or updating a record
+type recordWrite struct {
+	Zone  int     `json:"zone"`
+	Name  string  `json:"name"`
+	Type  string  `json:"type"`
+	Value string  `json:"value"`
+	TTL   *uint32 `json:"ttl"`
+}
+
+func (server *Server) handleViews(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	writePage(writer, request, filterRefs(server.views, request), server.PageSize)
+}
+
+func (server *Server) handleNameServers(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	writePage(
+		writer,
+		request,
+		filterRefs(server.nameServers, request),
+		server.PageSize,
+	)
+}
+
+func (server *Server) handleCreateView(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	created, ok := createRef(writer, request, &server.views, "view")
+	if ok {
+		writeJSONStatus(writer, http.StatusCreated, created)
+	}
+}
+
+func (server *Server) handleCreateNameServer(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	created, ok := createRef(writer, request, &server.nameServers, "nameserver")
+	if ok {
+		writeJSONStatus(writer, http.StatusCreated, created)
+	}
+}
+
+func (server *Server) handleCreateZone(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	var body zoneWrite
+	if !decodeBody(writer, request, &body) {
+		return
+	}
+	newZone := zone{ID: len(server.zones) + 1, Status: "active"}
+	if !server.applyZone(writer, &newZone, body) {
+		return
+	}
+	server.zones = append(server.zones, newZone)
+	server.addZoneRecords(newZone)
+	writeJSONStatus(writer, http.StatusCreated, newZone)
+}
+
+func (server *Server) handleUpdateZone(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	id, err := strconv.Atoi(request.PathValue("id"))
+	if err != nil || id <= 0 || id > len(server.zones) {
+		writeError(writer, http.StatusNotFound, "No Zone matches the given query.")
+		return
+	}
+	current := &server.zones[id-1]
+	body := zoneWrite{
+		Name:       current.Name,
+		View:       current.View.ID,
+		DefaultTTL: current.DefaultTTL,
+		SOATTL:     current.SOATTL,
+		SOAMName:   current.SOAMName.ID,
+		SOARName:   current.SOARName,
+		SOASerial:  current.SOASerial,
+		SOARefresh: current.SOARefresh,
+		SOARetry:   current.SOARetry,
+		SOAExpire:  current.SOAExpire,
+		SOAMinimum: current.SOAMinimum,
+	}
+	for _, nameServer := range current.NameServers {
+		body.NameServers = append(body.NameServers, nameServer.ID)
+	}
+	if !decodeBody(writer, request, &body) {
+		return
+	}
+	updated := *current
+	if !server.applyZone(writer, &updated, body) {
+		return
+	}
+	*current = updated
+	// the SOA and NS records follow the zone, as in Netbox
+	server.records = slices.DeleteFunc(server.records, func(r record) bool {
+		return r.Zone.ID == updated.ID && r.Managed && r.Name == "@" &&
+			(r.Type == "SOA" || r.Type == "NS")
+	})
+	server.addZoneRecords(updated)
+	writeJSON(writer, updated)
+}
+
+// applyZone validates body and sets the fields of target from it
+func (server *Server) applyZone(
+	writer http.ResponseWriter,
+	target *zone,
+	body zoneWrite,
+) bool {
+	name := netbox.NormalizeName(body.Name)
+	if name == "" {
+		writeFieldError(writer, "name", "This field is required.")
+		return false
+	}
+	view, ok := findRef(server.views, body.View)
+	if !ok {
+		writeFieldError(writer, "view", "Invalid pk - object does not exist.")
+		return false
+	}
+	for _, existing := range server.zones {
+		if existing.ID != target.ID && existing.View.ID == view.ID &&
+			netbox.NormalizeName(existing.Name) == name {
+			writeFieldError(
+				writer,
+				"name",
+				"Zone with this View and Name already exists.",
+			)
+			return false
+		}
+	}
+	mname, ok := findRef(server.nameServers, body.SOAMName)
+	if !ok {
+		writeFieldError(writer, "soa_mname", "Invalid pk - object does not exist.")
+		return false
+	}
+	nameServers := make([]objectRef, 0, len(body.NameServers))
+	for _, id := range body.NameServers {
+		nameServer, ok := findRef(server.nameServers, id)
+		if !ok {
+			writeFieldError(
+				writer,
+				"nameservers",
+				"Invalid pk - object does not exist.",
+			)
+			return false
+		}
+		nameServers = append(nameServers, nameServer)
+	}
+	target.Name = name
+	target.View = view
+	target.NameServers = nameServers
+	target.DefaultTTL = body.DefaultTTL
+	target.SOATTL = body.SOATTL
+	target.SOAMName = mname
+	target.SOARName = strings.TrimSuffix(body.SOARName, ".")
+	target.SOASerial = body.SOASerial
+	target.SOARefresh = body.SOARefresh
+	target.SOARetry = body.SOARetry
+	target.SOAExpire = body.SOAExpire
+	target.SOAMinimum = body.SOAMinimum
+	return true
+}
+
+func (server *Server) handleCreateRecord(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	var body recordWrite
+	if !decodeBody(writer, request, &body) {
+		return
+	}
+	recordZone, ok := server.validateRecord(writer, body)
+	if !ok {
+		return
+	}
+	created := server.addRecord(
+		recordZone,
+		body.Name,
+		body.Type,
+		body.Value,
+		body.TTL,
+		false,
+	)
+	writeJSONStatus(writer, http.StatusCreated, created)
+}
+
+func (server *Server) handleUpdateRecord(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	index, ok := server.findRecord(writer, request)
+	if !ok {
+		return
+	}
+	current := server.records[index]
+	body := recordWrite{
+		Zone:  current.Zone.ID,
+		Name:  current.Name,
+		Type:  current.Type,
+		Value: current.Value,
+		TTL:   current.TTL,
+	}
+	if !decodeBody(writer, request, &body) {
+		return
+	}
+	recordZone, ok := server.validateRecord(writer, body)
+	if !ok {
+		return
+	}
+	updated := newRecord(
+		recordZone,
+		body.Name,
+		body.Type,
+		body.Value,
+		body.TTL,
+		false,
+	)
+	updated.ID = current.ID
+	server.records[index] = updated
+	writeJSON(writer, updated)
+}
+
+func (server *Server) handleDeleteRecord(
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	index, ok := server.findRecord(writer, request)
+	if !ok {
+		return
+	}
+	server.records = slices.Delete(server.records, index, index+1)
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// findRecord returns the index of the record with the ID in the request path,
+// which must not be managed
+func (server *Server) findRecord(
+	writer http.ResponseWriter,
+	request *http.Request,
+) (int, bool) {
+	id, _ := strconv.Atoi(request.PathValue("id"))
+	index := slices.IndexFunc(server.records, func(r record) bool {
+		return r.ID == id
+	})
+	if index < 0 {
+		writeError(writer, http.StatusNotFound, "No Record matches the given query.")
+		return 0, false
+	}
+	if server.records[index].Managed {
+		writeError(
+			writer,
+			http.StatusBadRequest,
+			"Managed records cannot be modified.",
+		)
+		return 0, false
+	}
+	return index, true
+}
+
+// validateRecord returns the zone of the record in body if its value can be
+// parsed, as Netbox validates the values of records
+func (server *Server) validateRecord(
+	writer http.ResponseWriter,
+	body recordWrite,
+) (zone, bool) {
+	if body.Zone <= 0 || body.Zone > len(server.zones) {
+		writeFieldError(writer, "zone", "Invalid pk - object does not exist.")
+		return zone{}, false
+	}
+	if body.Name == "" {
+		writeFieldError(writer, "name", "This field is required.")
+		return zone{}, false
+	}
+	if _, ok := dns.StringToType[body.Type]; !ok {
+		writeFieldError(
+			writer,
+			"type",
+			fmt.Sprintf("%q is not a valid choice.", body.Type),
+		)
+		return zone{}, false
+	}
+	if _, err := dns.NewRR(". 0 IN " + body.Type + " " + body.Value); err != nil {
+		writeFieldError(writer, "value", err.Error())
+		return zone{}, false
+	}
+	return server.zones[body.Zone-1], true
+}
+
+func filterRefs(refs []objectRef, request *http.Request) []objectRef {
+	names := request.URL.Query()["name"]
+	results := make([]objectRef, 0, len(refs))
+	for _, candidate := range refs {
+		if len(names) == 0 || containsName(names, candidate.Name) {
+			results = append(results, candidate)
+		}
+	}
+	return results
+}
+
+func findRef(refs []objectRef, id int) (objectRef, bool) {
+	for _, candidate := range refs {
+		if candidate.ID == id {
+			return candidate, true
+		}
+	}
+	return objectRef{}, false
+}
+
+// createRef adds the object named in the request body to refs, rejecting
+// duplicate names as Netbox does
+func createRef(
+	writer http.ResponseWriter,
+	request *http.Request,
+	refs *[]objectRef,
+	kind string,
+) (objectRef, bool) {
+	var body objectRef
+	if !decodeBody(writer, request, &body) {
+		return objectRef{}, false
+	}
+	body.Name = strings.TrimSuffix(body.Name, ".")
+	if body.Name == "" {
+		writeFieldError(writer, "name", "This field is required.")
+		return objectRef{}, false
+	}
+	for _, existing := range *refs {
+		if netbox.NormalizeName(existing.Name) == netbox.NormalizeName(body.Name) {
+			writeFieldError(
+				writer,
+				"name",
+				fmt.Sprintf("A %s with this name already exists.", kind),
+			)
+			return objectRef{}, false
+		}
+	}
+	body.ID = len(*refs) + 1
+	*refs = append(*refs, body)
+	return body, true
+}
+
+func decodeBody(
+	writer http.ResponseWriter,
+	request *http.Request,
+	body any,
+) bool {
+	if err := json.NewDecoder(request.Body).Decode(body); err != nil {
+		writeError(writer, http.StatusBadRequest, "JSON parse error - "+err.Error())
+		return false
+	}
+	return true
+}
+
+// writeFieldError writes a validation error of field, as Netbox does
+func writeFieldError(writer http.ResponseWriter, field string, message string) {
+	writeJSONStatus(writer, http.StatusBadRequest, map[string][]string{
+		field: {message},
+	})
+}
@@ -0,0 +1,94 @@
+package netboxdns
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+// invalidRecordLogInterval is how often a skipped record is logged, so that a
+// frequently queried name does not flood the log
+const invalidRecordLogInterval time.Duration = time.Minute
+
+type invalidRecordsMode int
+
+const (
+	invalidRecordsFail    invalidRecordsMode = iota // SERVFAIL the response
+	invalidRecordsSkip                              // leave the record out
+	invalidRecordsSkipEDE                           // leave the record out and add an EDE
+)
+
+// skippedRecords holds the IDs of the invalid records left out of a response
+type skippedRecords []int
+
+func (skipped *skippedRecords) add(id int) {
+	if !slices.Contains(*skipped, id) {
+		*skipped = append(*skipped, id)
+	}
+}
+
+// invalidRecordLog logs each skipped record at most once per interval
+type invalidRecordLog struct {
+	interval time.Duration
+
+	mutex  sync.Mutex
+	logged map[int]time.Time
+}
+
+func newInvalidRecordLog(interval time.Duration) *invalidRecordLog {
+	return &invalidRecordLog{
+		interval: interval,
+		logged:   make(map[int]time.Time),
+	}
+}
+
+func (invalidLog *invalidRecordLog) log(recordErr *netbox.RecordError) {
+	now := time.Now()
+	invalidLog.mutex.Lock()
+	defer invalidLog.mutex.Unlock()
+	if last, ok := invalidLog.logged[recordErr.Record.ID]; ok &&
+		now.Sub(last) < invalidLog.interval {
+		return
+	}
+	// records that are fixed or deleted are forgotten once their interval
+	// has passed
+	for id, last := range invalidLog.logged {
+		if now.Sub(last) >= invalidLog.interval {
+			delete(invalidLog.logged, id)
+		}
+	}
+	invalidLog.logged[recordErr.Record.ID] = now
+	logger.Warningf("skipping record: %v", recordErr)
+}
+
+// recordsToRR converts records into resource records. Unless invalid records
+// are skipped, the first record that cannot be parsed fails the conversion;
+// otherwise it is logged, counted, and added to skipped.
+func (netboxdns *NetboxDNS) recordsToRR(
+	records []netbox.Record,
+	skipped *skippedRecords,
+) ([]dns.RR, error) {
+	if netboxdns.invalidRecords == invalidRecordsFail {
+		return netbox.RecordsToRR(records)
+	}
+	out := make([]dns.RR, 0, len(records))
+	for _, record := range records {
+		rr, err := netbox.RecordToRR(record)
+		var recordErr *netbox.RecordError
+		if errors.As(err, &recordErr) {
+			invalidRecordsSkipped.Inc()
+			netboxdns.invalidLog.log(recordErr)
+			skipped.add(record.ID)
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, rr)
+	}
+	return out, nil
+}
@@ -1,6 +1,7 @@
 package netboxdns
 
 import (
+	"context"
 	"strings"
 
 	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
@@ -15,21 +16,42 @@ const (
 	lookupDelegation              // Delegate, non-authoritative
 )
 
+func (result lookupResult) String() string {
+	switch result {
+	case lookupSuccess:
+		return "success"
+	case lookupNameError:
+		return "nxdomain"
+	case lookupDelegation:
+		return "delegation"
+	default:
+		return "unknown"
+	}
+}
+
 type lookupResponse struct {
 	Answer       []dns.RR
 	Ns           []dns.RR
 	Extra        []dns.RR
 	LookupResult lookupResult
+	// Skipped holds the IDs of invalid records left out of the response
+	Skipped skippedRecords
 }
 
 func (netboxdns *NetboxDNS) lookup(
+	ctx context.Context,
 	name string,
 	qtype uint16,
 	family int,
 ) (*lookupResponse, error) {
+	span, ctx := netbox.StartSpan(ctx, "netboxdns.lookup")
+	defer span.Finish()
+	span.SetTag("qname", name)
+	span.SetTag("qtype", dns.TypeToString[qtype])
+
 	nameTrimmed := strings.TrimSuffix(name, ".")
 	// check if zone exists on Netbox
-	zone, err := netboxdns.matchZone(nameTrimmed)
+	zone, err := netboxdns.matchZone(ctx, nameTrimmed)
 	if err != nil {
 		return nil, err
 	}
@@ -37,10 +59,28 @@ func (netboxdns *NetboxDNS) lookup(
 		logger.Debugf("no zone matching %q", name)
 		return &lookupResponse{LookupResult: lookupNameError}, nil
 	}
+	span.SetTag("zone", zone.Name)
+	skipped := &skippedRecords{}
+
+	if netboxdns.negativeCache != nil &&
+		netboxdns.negativeCache.contains(zone, nameTrimmed, qtype) {
+		logger.Debugf(
+			"negative cache hit for [%s] %q",
+			dns.TypeToString[qtype],
+			name,
+		)
+		return &lookupResponse{LookupResult: lookupNameError}, nil
+	}
 
 	// check if qname is for zone origin
 	if nameTrimmed == zone.Name {
-		originResponse, err := netboxdns.processOrigin(qtype, zone, family)
+		originResponse, err := netboxdns.processOrigin(
+			ctx,
+			qtype,
+			zone,
+			family,
+			skipped,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -50,12 +90,20 @@ func (netboxdns *NetboxDNS) lookup(
 				dns.TypeToString[qtype],
 				name,
 			)
+			originResponse.Skipped = *skipped
 			return originResponse, nil
 		}
 	}
 
 	// lookup exact request
-	direct, err := netboxdns.lookupDirect(nameTrimmed, qtype, zone, family)
+	direct, err := netboxdns.lookupDirect(
+		ctx,
+		nameTrimmed,
+		qtype,
+		zone,
+		family,
+		skipped,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -65,48 +113,75 @@ func (netboxdns *NetboxDNS) lookup(
 			dns.TypeToString[qtype],
 			name,
 		)
+		direct.Skipped = *skipped
 		return direct, nil
 	}
 
 	// if no exact records exist for the request, check if the qname is a
 	// delegate zone
-	delegate, err := netboxdns.lookupDelegate(nameTrimmed, zone, family)
+	delegate, err := netboxdns.lookupDelegate(
+		ctx,
+		nameTrimmed,
+		zone,
+		family,
+		skipped,
+	)
 	if err != nil {
 		return nil, err
 	}
 	if delegate != nil {
 		logger.Debugf("found delegate zone records for %q", name)
+		delegate.Skipped = *skipped
 		return delegate, nil
 	}
 
 	logger.Debugf("no records found for [%s] %q", dns.TypeToString[qtype], name)
-	return &lookupResponse{LookupResult: lookupNameError}, nil
+	if netboxdns.negativeCache != nil && len(*skipped) == 0 {
+		netboxdns.negativeCache.add(zone, nameTrimmed, qtype)
+	}
+	return &lookupResponse{
+		LookupResult: lookupNameError,
+		Skipped:      *skipped,
+	}, nil
 }
 
-func (netboxdns *NetboxDNS) matchZone(qname string) (*netbox.Zone, error) {
-	managedZones, err := netbox.GetZones(netboxdns.requestClient)
+func (netboxdns *NetboxDNS) matchZone(
+	ctx context.Context,
+	qname string,
+) (*netbox.Zone, error) {
+	span, ctx := netbox.StartSpan(ctx, "netboxdns.matchZone")
+	defer span.Finish()
+	index, err := netboxdns.zoneIndex(ctx)
 	if err != nil {
 		return nil, err
 	}
-	var out *netbox.Zone
-	for _, managedZone := range managedZones {
-		if dns.IsSubDomain(managedZone.Name, qname) {
-			if out == nil {
-				out = &managedZone
-			}
-			if len(managedZone.Name) > len(out.Name) {
-				out = &managedZone
-			}
-		}
+	netboxdns.ready.Store(true)
+	return index.match(qname), nil
+}
+
+// zoneIndex returns the zones provided by the backend, from the zone cache if it is
+// enabled
+func (netboxdns *NetboxDNS) zoneIndex(ctx context.Context) (zoneIndex, error) {
+	if netboxdns.zoneCache != nil {
+		return netboxdns.zoneCache.get(ctx, netboxdns.backend)
+	}
+	managedZones, err := netboxdns.backend.Zones(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return out, nil
+	return newZoneIndex(managedZones), nil
 }
 
 func (netboxdns *NetboxDNS) processOrigin(
+	ctx context.Context,
 	qtype uint16,
 	zone *netbox.Zone,
 	family int,
+	skipped *skippedRecords,
 ) (*lookupResponse, error) {
+	span, ctx := netbox.StartSpan(ctx, "netboxdns.processOrigin")
+	defer span.Finish()
+	span.SetTag("zone", zone.Name)
 	var queryType []string
 	switch qtype {
 	case dns.TypeSOA:
@@ -116,8 +191,8 @@ func (netboxdns *NetboxDNS) processOrigin(
 	default:
 		return nil, nil
 	}
-	records, err := netbox.GetRecordsQuery(
-		netboxdns.requestClient,
+	records, err := netboxdns.backend.Records(
+		ctx,
 		&netbox.RecordQuery{
 			Name: "@",
 			Type: queryType,
@@ -127,25 +202,25 @@ func (netboxdns *NetboxDNS) processOrigin(
 	if err != nil {
 		return nil, err
 	}
-	rrs, err := recordsToRR(records)
+	rrs, err := netboxdns.recordsToRR(records, skipped)
 	if err != nil {
 		return nil, err
 	}
 	answer := filterRRByType(rrs, dns.TypeSOA)
 	ns := filterRRByType(rrs, dns.TypeNS)
-	extraRecords, err := netboxdns.processExtra(ns, zone, family)
+	extraRecords, err := netboxdns.processExtra(ctx, ns, zone, family)
 	if err != nil {
 		return nil, err
 	}
 	if len(extraRecords) == 0 {
 		// if no A/AAAA records exist for the NS in the specified zone, check if
 		// the server has records anywhere
-		extraRecords, err = netboxdns.processExtra(ns, nil, family)
+		extraRecords, err = netboxdns.processExtra(ctx, ns, nil, family)
 		if err != nil {
 			return nil, err
 		}
 	}
-	extra, err := recordsToRR(extraRecords)
+	extra, err := netboxdns.recordsToRR(extraRecords, skipped)
 	if err != nil {
 		return nil, err
 	}
@@ -161,11 +236,19 @@ func (netboxdns *NetboxDNS) processOrigin(
 }
 
 func (netboxdns *NetboxDNS) processExtra(
+	ctx context.Context,
 	answer []dns.RR,
 	zone *netbox.Zone,
 	family int,
 ) ([]netbox.Record, error) {
-	var out []netbox.Record
+	span, ctx := netbox.StartSpan(ctx, "netboxdns.processExtra")
+	defer span.Finish()
+	if zone != nil {
+		span.SetTag("zone", zone.Name)
+	}
+	// resolve every target in a single request
+	names := make([]string, 0, len(answer))
+	seen := make(map[string]bool, len(answer))
 	for _, rr := range answer {
 		name := ""
 		switch t := rr.(type) {
@@ -178,46 +261,53 @@ func (netboxdns *NetboxDNS) processExtra(
 		case *dns.CNAME:
 			name = t.Target
 		}
-		if len(name) == 0 {
+		name = strings.TrimSuffix(name, ".")
+		key := strings.ToLower(name)
+		if len(name) == 0 || seen[key] {
 			continue
 		}
-		var reqType []string
-		switch family {
-		case 1:
-			reqType = []string{"A"}
-		case 2:
-			reqType = []string{"AAAA"}
-		}
-		records, err := netbox.GetRecordsQuery(
-			netboxdns.requestClient,
-			&netbox.RecordQuery{
-				FQDN: strings.TrimSuffix(name, "."),
-				Type: reqType,
-				Zone: zone,
-			},
-		)
-		if err != nil {
-			return out, err
-		}
-		out = append(out, records...)
+		seen[key] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, nil
 	}
-	return out, nil
+	var reqType []string
+	switch family {
+	case 1:
+		reqType = []string{"A"}
+	case 2:
+		reqType = []string{"AAAA"}
+	}
+	return netboxdns.backend.Records(
+		ctx,
+		&netbox.RecordQuery{
+			FQDN: names,
+			Type: reqType,
+			Zone: zone,
+		},
+	)
 }
 
 func (netboxdns *NetboxDNS) lookupDirect(
+	ctx context.Context,
 	qname string,
 	qtype uint16,
 	zone *netbox.Zone,
 	family int,
+	skipped *skippedRecords,
 ) (*lookupResponse, error) {
+	span, ctx := netbox.StartSpan(ctx, "netboxdns.lookupDirect")
+	defer span.Finish()
+	span.SetTag("zone", zone.Name)
 	queryTypes := []string{dns.TypeToString[qtype]}
 	if qtype == dns.TypeA || qtype == dns.TypeAAAA {
 		queryTypes = append(queryTypes, "CNAME")
 	}
-	records, err := netbox.GetRecordsQuery(
-		netboxdns.requestClient,
+	records, err := netboxdns.backend.Records(
+		ctx,
 		&netbox.RecordQuery{
-			FQDN: qname,
+			FQDN: []string{qname},
 			Type: queryTypes,
 			Zone: zone,
 		},
@@ -227,15 +317,15 @@ func (netboxdns *NetboxDNS) lookupDirect(
 	}
 
 	if len(records) > 0 {
-		answer, err := recordsToRR(records)
+		answer, err := netboxdns.recordsToRR(records, skipped)
 		if err != nil {
 			return nil, err
 		}
-		extraRecords, err := netboxdns.processExtra(answer, zone, family)
+		extraRecords, err := netboxdns.processExtra(ctx, answer, zone, family)
 		if err != nil {
 			return nil, err
 		}
-		extra, err := recordsToRR(extraRecords)
+		extra, err := netboxdns.recordsToRR(extraRecords, skipped)
 		if err != nil {
 			return nil, err
 		}
@@ -253,14 +343,19 @@ func (netboxdns *NetboxDNS) lookupDirect(
 }
 
 func (netboxdns *NetboxDNS) lookupDelegate(
+	ctx context.Context,
 	qname string,
 	zone *netbox.Zone,
 	family int,
+	skipped *skippedRecords,
 ) (*lookupResponse, error) {
-	records, err := netbox.GetRecordsQuery(
-		netboxdns.requestClient,
+	span, ctx := netbox.StartSpan(ctx, "netboxdns.lookupDelegate")
+	defer span.Finish()
+	span.SetTag("zone", zone.Name)
+	records, err := netboxdns.backend.Records(
+		ctx,
 		&netbox.RecordQuery{
-			FQDN: qname,
+			FQDN: []string{qname},
 			Type: []string{"NS"},
 			Zone: zone,
 		},
@@ -269,15 +364,20 @@ func (netboxdns *NetboxDNS) lookupDelegate(
 		return nil, err
 	}
 	if len(records) > 0 {
-		ns, err := recordsToRR(records)
+		ns, err := netboxdns.recordsToRR(records, skipped)
 		if err != nil {
 			return nil, err
 		}
-		extraRecords, err := netboxdns.processExtra(ns, nil, family)
+		if len(ns) == 0 {
+			// every NS record was skipped, so there is no delegation to
+			// refer to
+			return nil, nil
+		}
+		extraRecords, err := netboxdns.processExtra(ctx, ns, nil, family)
 		if err != nil {
 			return nil, err
 		}
-		extra, err := recordsToRR(extraRecords)
+		extra, err := netboxdns.recordsToRR(extraRecords, skipped)
 		if err != nil {
 			return nil, err
 		}
@@ -289,3 +389,13 @@ func (netboxdns *NetboxDNS) lookupDelegate(
 	}
 	return nil, nil
 }
+
+func filterRRByType(rrs []dns.RR, recordType uint16) []dns.RR {
+	out := make([]dns.RR, 0)
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == recordType {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
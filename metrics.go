@@ -0,0 +1,52 @@
+package netboxdns
+
+import (
+	"github.com/coredns/coredns/plugin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Variables declared for monitoring.
+var (
+	lookupCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "lookups_total",
+		Help:      "Counter of lookups against Netbox per server and result.",
+	}, []string{"server", "result"})
+
+	negativeCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "negative_cache_hits_total",
+		Help:      "Counter of lookups answered from the negative cache.",
+	})
+
+	negativeCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "negative_cache_misses_total",
+		Help:      "Counter of lookups not found in the negative cache.",
+	})
+
+	invalidRecordsSkipped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "invalid_records_skipped_total",
+		Help:      "Counter of records left out of responses as they could not be parsed.",
+	})
+
+	zoneCacheSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "zone_cache_zones",
+		Help:      "Number of zones held in the zone cache per instance.",
+	}, []string{"zones"})
+
+	zoneCacheRefreshed = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "zone_cache_refresh_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful zone cache refresh per instance.",
+	}, []string{"zones"})
+)
@@ -0,0 +1,123 @@
+package netboxdns
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+)
+
+const defaultNegativeCacheCapacity int = 10000
+
+type negativeCacheKey struct {
+	view  string
+	qname string
+	qtype uint16
+}
+
+// negativeCache remembers names that Netbox has no records for, so that
+// repeated queries for them do not each cost several requests to Netbox.
+// Entries expire after the negative caching TTL of their zone, and every entry
+// is dropped when the zone cache, if enabled, is refreshed from Netbox.
+type negativeCache struct {
+	capacity int
+
+	mutex   sync.Mutex
+	entries map[negativeCacheKey]time.Time
+}
+
+func newNegativeCache(capacity int) *negativeCache {
+	return &negativeCache{
+		capacity: capacity,
+		entries:  make(map[negativeCacheKey]time.Time),
+	}
+}
+
+func newNegativeCacheKey(
+	zone *netbox.Zone,
+	qname string,
+	qtype uint16,
+) negativeCacheKey {
+	return negativeCacheKey{
+		view:  zone.View.Name,
+		qname: strings.ToLower(qname),
+		qtype: qtype,
+	}
+}
+
+// negativeTTL returns the TTL for negative answers from zone as defined by
+// RFC 2308: the lesser of the SOA record TTL and the SOA minimum field
+func negativeTTL(zone *netbox.Zone) time.Duration {
+	ttl := zone.SOAMinimum
+	if zone.SOATTL < ttl {
+		ttl = zone.SOATTL
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// contains reports whether the name is cached as having no records
+func (cache *negativeCache) contains(
+	zone *netbox.Zone,
+	qname string,
+	qtype uint16,
+) bool {
+	key := newNegativeCacheKey(zone, qname, qtype)
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	expires, ok := cache.entries[key]
+	if !ok {
+		negativeCacheMisses.Inc()
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(cache.entries, key)
+		negativeCacheMisses.Inc()
+		return false
+	}
+	negativeCacheHits.Inc()
+	return true
+}
+
+func (cache *negativeCache) add(
+	zone *netbox.Zone,
+	qname string,
+	qtype uint16,
+) {
+	ttl := negativeTTL(zone)
+	if ttl <= 0 {
+		return
+	}
+	key := newNegativeCacheKey(zone, qname, qtype)
+	now := time.Now()
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if len(cache.entries) >= cache.capacity {
+		cache.evict(now)
+	}
+	cache.entries[key] = now.Add(ttl)
+}
+
+// evict removes expired entries, or an arbitrary entry if none have expired.
+// The caller must hold the mutex.
+func (cache *negativeCache) evict(now time.Time) {
+	for key, expires := range cache.entries {
+		if now.After(expires) {
+			delete(cache.entries, key)
+		}
+	}
+	if len(cache.entries) < cache.capacity {
+		return
+	}
+	for key := range cache.entries {
+		delete(cache.entries, key)
+		return
+	}
+}
+
+// purge removes every entry from the cache
+func (cache *negativeCache) purge() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	clear(cache.entries)
+}
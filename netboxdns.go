@@ -3,9 +3,11 @@ package netboxdns
 import (
 	"context"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
 	"github.com/coredns/coredns/plugin/pkg/fall"
 	"github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/request"
@@ -20,6 +22,14 @@ const (
 
 var logger log.P
 
+type startupCheckMode int
+
+const (
+	startupCheckNone startupCheckMode = iota
+	startupCheckFail                  // fail setup if the check fails
+	startupCheckWarn                  // log a warning if the check fails
+)
+
 func init() {
 	logger = log.NewWithPlugin(pluginName)
 }
@@ -27,25 +37,44 @@ func init() {
 type NetboxDNS struct {
 	Next plugin.Handler
 
+	// backend provides the zones and records served. Unless a "file" is
+	// configured, it is requestClient.
+	backend       netbox.Backend
 	requestClient *netbox.APIRequestClient
 
-	zones []string
-	fall  fall.F
+	zones         []string
+	fall          fall.F
+	queryTimeout  time.Duration
+	zoneCache     *zoneCache
+	negativeCache *negativeCache
+	startupCheck  startupCheckMode
+
+	invalidRecords invalidRecordsMode
+	invalidLog     *invalidRecordLog
+
+	ready  atomic.Bool
+	cancel context.CancelFunc
+
+	// instances holds the configurations of any further netboxdns directives
+	// in the same server block, each serving its own zones
+	instances []*NetboxDNS
 }
 
 func NewNetboxDNS() *NetboxDNS {
-	return &NetboxDNS{
-		requestClient: &netbox.APIRequestClient{
-			Client: &http.Client{
-				Timeout: defaultHTTPClientTimeout,
-			},
+	requestClient := &netbox.APIRequestClient{
+		Client: &http.Client{
+			Timeout: defaultHTTPClientTimeout,
 		},
-		zones: []string{"."},
+	}
+	return &NetboxDNS{
+		backend:       requestClient,
+		requestClient: requestClient,
+		zones:         []string{"."},
 	}
 }
 
 // Name implements the plugin.Handler interface
-func (NetboxDNS) Name() string {
+func (*NetboxDNS) Name() string {
 	return pluginName
 }
 
@@ -56,20 +85,63 @@ func (netboxdns *NetboxDNS) ServeDNS(
 	reqMsg *dns.Msg,
 ) (int, error) {
 	state := request.Request{W: respWriter, Req: reqMsg}
+
+	// check if plugin is configured to respond to the requested zone
+	instance := netboxdns.match(state.QName())
+	if instance == nil {
+		return netboxdns.nextOrFailure(reqContext, respWriter, reqMsg)
+	}
+	return instance.serve(reqContext, state)
+}
+
+// match returns the configured instance with the longest zone that qname is
+// equal to or a subdomain of, or nil if there is none
+func (netboxdns *NetboxDNS) match(qname string) *NetboxDNS {
+	var (
+		instance       *NetboxDNS
+		respondingZone string
+	)
+	for _, candidate := range netboxdns.all() {
+		zone := plugin.Zones(candidate.zones).Matches(qname)
+		if len(zone) > len(respondingZone) {
+			instance, respondingZone = candidate, zone
+		}
+	}
+	return instance
+}
+
+// all returns this instance followed by any further configured instances
+func (netboxdns *NetboxDNS) all() []*NetboxDNS {
+	return append([]*NetboxDNS{netboxdns}, netboxdns.instances...)
+}
+
+// serve answers a query for one of the instance's zones
+func (netboxdns *NetboxDNS) serve(
+	reqContext context.Context,
+	state request.Request,
+) (int, error) {
+	respWriter, reqMsg := state.W, state.Req
 	qname := state.QName()
 	family := state.Family()
 	qtype := fixQType(state.QType(), family)
 
-	// check if plugin is configured to respond to the requested zone
-	respondingZone := plugin.Zones(netboxdns.zones).Matches(qname)
-	if respondingZone == "" {
-		return netboxdns.nextOrFailure(reqContext, respWriter, reqMsg)
+	lookupContext := reqContext
+	if netboxdns.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		lookupContext, cancel = context.WithTimeout(
+			reqContext,
+			netboxdns.queryTimeout,
+		)
+		defer cancel()
 	}
 
-	response, err := netboxdns.lookup(qname, qtype, family)
+	server := metrics.WithServer(reqContext)
+	response, err := netboxdns.lookup(lookupContext, qname, qtype, family)
 	if err != nil {
-		return dns.RcodeServerFailure, err
+		lookupCount.WithLabelValues(server, "error").Inc()
+		return serverFailure(state, err)
 	}
+	lookupCount.WithLabelValues(server, response.LookupResult.String()).Inc()
 	if response.LookupResult == lookupNameError {
 		if netboxdns.fall.Through(qname) {
 			logger.Debugf(
@@ -103,10 +175,39 @@ func (netboxdns *NetboxDNS) ServeDNS(
 		respMsg.Authoritative = false
 	}
 
+	// echo the client's OPT record and fit the response to the advertised
+	// buffer size; additional records are dropped before the answer is
+	// truncated and TC is set
+	if state.SizeAndDo(respMsg) &&
+		netboxdns.invalidRecords == invalidRecordsSkipEDE &&
+		len(response.Skipped) > 0 {
+		opt := respMsg.IsEdns0()
+		opt.Option = append(opt.Option, skippedRecordsError(response.Skipped))
+	}
+	respMsg = state.Scrub(respMsg)
+
 	respWriter.WriteMsg(respMsg)
 	return dns.RcodeSuccess, nil
 }
 
+// serverFailure writes a SERVFAIL response carrying an Extended DNS Error that
+// describes why the lookup failed. If the client did not send an OPT record,
+// the response is left to the server to write.
+func serverFailure(state request.Request, err error) (int, error) {
+	if state.Req.IsEdns0() == nil {
+		return dns.RcodeServerFailure, err
+	}
+	respMsg := new(dns.Msg)
+	respMsg.SetRcode(state.Req, dns.RcodeServerFailure)
+	state.SizeAndDo(respMsg)
+	opt := respMsg.IsEdns0()
+	opt.Option = append(opt.Option, extendedError(err))
+	state.W.WriteMsg(respMsg)
+	// the response has already been written, but the error is still returned
+	// so that it is logged by the errors plugin
+	return dns.RcodeSuccess, err
+}
+
 func (netboxdns *NetboxDNS) nextOrFailure(
 	ctx context.Context,
 	writer dns.ResponseWriter,
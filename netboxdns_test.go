@@ -2,16 +2,27 @@ package netboxdns
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netboxtest"
 	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestPluginName(t *testing.T) {
@@ -36,14 +47,45 @@ var testFamilyToString map[testFamily]string = map[testFamily]string{
 
 const (
 	testInstanceToken   string = "w5pgWXPqZVmngLN4w4XwuPvZfUC72ytDxnnHgEmI"
-	testInstanceUrlHost string = "localhost:9999"
 	testInstanceUrlPath string = "/api/plugins/netbox-dns/"
 )
 
-var netboxdnsPlugin NetboxDNS = NetboxDNS{
-	Next:  test.ErrorHandler(),
-	zones: []string{"."},
-	requestClient: &netbox.APIRequestClient{
+// testInstanceUrlHost is the address of the Netbox instance that the lookup
+// tests run against: a fake seeded from .testing/init, or the instance at
+// NETBOXDNS_TEST_NETBOX if it is set, such as "localhost:9999" for the Docker
+// Compose instance
+var testInstanceUrlHost string
+
+var netboxdnsPlugin NetboxDNS
+
+var (
+	recordFixtures = flag.String(
+		"record",
+		"",
+		"save the Netbox responses to the lookup tests to this fixture file",
+	)
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	testInstanceUrlHost = os.Getenv("NETBOXDNS_TEST_NETBOX")
+	if testInstanceUrlHost == "" {
+		fixtures, err := netboxtest.LoadFixtures(".testing/init")
+		if err != nil {
+			log.Fatal(err)
+		}
+		server, err := netboxtest.NewServer(fixtures, testInstanceToken)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer server.Close()
+		testInstanceUrlHost = server.Listener.Addr().String()
+	}
+	requestClient := &netbox.APIRequestClient{
 		Client: &http.Client{
 			Timeout: time.Second * 30,
 		},
@@ -53,10 +95,38 @@ var netboxdnsPlugin NetboxDNS = NetboxDNS{
 			Path:   testInstanceUrlPath,
 		},
 		Token: testInstanceToken,
-	},
+	}
+	netboxdnsPlugin = NetboxDNS{
+		Next:    test.ErrorHandler(),
+		zones:   []string{"."},
+		backend: requestClient,
+	}
+	if *recordFixtures == "" {
+		return m.Run()
+	}
+
+	recorder := requestClient.Record()
+	code := m.Run()
+	// the startup check is recorded so that it can be replayed
+	if err := netbox.Check(context.Background(), requestClient); err != nil {
+		log.Printf("netbox startup check failed: %v", err)
+	}
+	if err := recorder.Save(*recordFixtures); err != nil {
+		log.Fatal(err)
+	}
+	return code
 }
 
 func RunTestLookup(t *testing.T, tcs []test.Case, family testFamily) {
+	RunTestLookupWith(t, &netboxdnsPlugin, tcs, family)
+}
+
+func RunTestLookupWith(
+	t *testing.T,
+	netboxdns *NetboxDNS,
+	tcs []test.Case,
+	family testFamily,
+) {
 	for _, tc := range tcs {
 		tcName := fmt.Sprintf(
 			"%s %s %s",
@@ -68,7 +138,7 @@ func RunTestLookup(t *testing.T, tcs []test.Case, family testFamily) {
 			msg := tc.Msg()
 			respWriter := GetTestResponseWriter(family)
 			rec := dnstest.NewRecorder(respWriter)
-			_, err := netboxdnsPlugin.ServeDNS(context.Background(), rec, msg)
+			_, err := netboxdns.ServeDNS(context.Background(), rec, msg)
 			if err != nil {
 				t.Errorf("expected no error, got %v", err)
 				return
@@ -709,11 +779,60 @@ func TestLookupUnknown(t *testing.T) {
 	RunTestLookup(t, testUnknownRecordsV6, testFamilyV6)
 }
 
+// TestReplayLookups runs the lookup tests against the Netbox responses recorded
+// in .testing/fixtures, without a Netbox instance
+func TestReplayLookups(t *testing.T) {
+	paths, err := filepath.Glob(".testing/fixtures/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("expected recorded fixtures, got none")
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			transport, err := netbox.NewReplayTransport(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			requestClient := &netbox.APIRequestClient{
+				Client: &http.Client{Transport: transport},
+				NetboxURL: &url.URL{
+					Scheme: "http",
+					Host:   "netbox.invalid",
+					Path:   testInstanceUrlPath,
+				},
+				Token: testInstanceToken,
+			}
+			if err := netbox.Check(context.Background(), requestClient); err != nil {
+				t.Errorf("expected startup check to pass, got %v", err)
+			}
+			netboxdns := &NetboxDNS{
+				Next:    test.ErrorHandler(),
+				zones:   []string{"."},
+				backend: requestClient,
+			}
+			RunTestLookupWith(t, netboxdns, testLookupForwardZonesCasesv4, testFamilyV4)
+			RunTestLookupWith(t, netboxdns, testLookupReverseZonesCasesv4, testFamilyV4)
+			RunTestLookupWith(t, netboxdns, testLookupForwardZonesCasesv6, testFamilyV6)
+			RunTestLookupWith(t, netboxdns, testLookupReverseZonesCasesv6, testFamilyV6)
+			RunTestLookupWith(t, netboxdns, testLookupRecordV4, testFamilyV4)
+			RunTestLookupWith(t, netboxdns, testLookupPTRV4, testFamilyV4)
+			RunTestLookupWith(t, netboxdns, testLookupRecordV6, testFamilyV6)
+			RunTestLookupWith(t, netboxdns, testLookupPTRV6, testFamilyV6)
+			RunTestLookupWith(t, netboxdns, testUnknownRecords, testFamilyV4)
+			RunTestLookupWith(t, netboxdns, testUnknownRecordsV4, testFamilyV4)
+			RunTestLookupWith(t, netboxdns, testUnknownRecordsV6, testFamilyV6)
+		})
+	}
+}
+
 func TestOffline(t *testing.T) {
 	netboxdns := NetboxDNS{
 		Next:  test.ErrorHandler(),
 		zones: []string{"."},
-		requestClient: &netbox.APIRequestClient{
+		backend: &netbox.APIRequestClient{
 			Client: &http.Client{
 				Timeout: defaultHTTPClientTimeout,
 			},
@@ -740,7 +859,7 @@ func TestUnauthorized(t *testing.T) {
 	netboxdns := NetboxDNS{
 		Next:  test.ErrorHandler(),
 		zones: []string{"."},
-		requestClient: &netbox.APIRequestClient{
+		backend: &netbox.APIRequestClient{
 			Client: &http.Client{
 				Timeout: defaultHTTPClientTimeout,
 			},
@@ -767,7 +886,7 @@ func TestFallthrough(t *testing.T) {
 	netboxdns := NetboxDNS{
 		Next:  test.ErrorHandler(),
 		zones: []string{exampledotcomName},
-		requestClient: &netbox.APIRequestClient{
+		backend: &netbox.APIRequestClient{
 			Client: &http.Client{
 				Timeout: defaultHTTPClientTimeout,
 			},
@@ -795,7 +914,7 @@ func TestUnhandledZone(t *testing.T) {
 	netboxdns := NetboxDNS{
 		Next:  test.ErrorHandler(),
 		zones: []string{exampledotcomName},
-		requestClient: &netbox.APIRequestClient{
+		backend: &netbox.APIRequestClient{
 			Client: &http.Client{
 				Timeout: defaultHTTPClientTimeout,
 			},
@@ -817,3 +936,612 @@ func TestUnhandledZone(t *testing.T) {
 		t.Errorf("expected no error, got %v", err)
 	}
 }
+
+func TestEDNS0(t *testing.T) {
+	tc := test.Case{
+		Qname: exampledotcomName, Qtype: dns.TypeSOA,
+	}
+	msg := tc.Msg()
+	msg.SetEdns0(1232, true)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	_, err := netboxdnsPlugin.ServeDNS(context.Background(), rec, msg)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	opt := rec.Msg.IsEdns0()
+	if opt == nil {
+		t.Fatal("expected OPT record in response, got none")
+	}
+	if opt.UDPSize() != 1232 {
+		t.Errorf("expected UDP size 1232, got %d", opt.UDPSize())
+	}
+	if !opt.Do() {
+		t.Error("expected DO bit to be echoed")
+	}
+	if rec.Msg.Truncated {
+		t.Error("expected response not to be truncated")
+	}
+}
+
+func TestOfflineExtendedError(t *testing.T) {
+	netboxdns := NetboxDNS{
+		Next:  test.ErrorHandler(),
+		zones: []string{"."},
+		backend: &netbox.APIRequestClient{
+			Client: &http.Client{
+				Timeout: defaultHTTPClientTimeout,
+			},
+			NetboxURL: &url.URL{
+				Scheme: "http",
+				Host:   "localhost:9876",
+				Path:   testInstanceUrlPath,
+			},
+			Token: testInstanceToken,
+		},
+	}
+	tc := test.Case{
+		Qname: exampledotcomName, Qtype: dns.TypeA, Do: true,
+	}
+	msg := tc.Msg()
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	_, err := netboxdns.ServeDNS(context.Background(), rec, msg)
+	if err == nil {
+		t.Error("expected connection error, got none")
+	}
+	if rec.Msg == nil {
+		t.Fatal("expected response to be written, got none")
+	}
+	if rec.Msg.Rcode != dns.RcodeServerFailure {
+		t.Errorf(
+			"expected rcode %s, got %s",
+			dns.RcodeToString[dns.RcodeServerFailure],
+			dns.RcodeToString[rec.Msg.Rcode],
+		)
+	}
+	opt := rec.Msg.IsEdns0()
+	if opt == nil {
+		t.Fatal("expected OPT record in response, got none")
+	}
+	var ede *dns.EDNS0_EDE
+	for _, option := range opt.Option {
+		if e, ok := option.(*dns.EDNS0_EDE); ok {
+			ede = e
+		}
+	}
+	if ede == nil {
+		t.Fatal("expected extended dns error, got none")
+	}
+	if ede.InfoCode != dns.ExtendedErrorCodeNetworkError {
+		t.Errorf(
+			"expected extended error %q, got %q",
+			dns.ExtendedErrorCodeToString[dns.ExtendedErrorCodeNetworkError],
+			dns.ExtendedErrorCodeToString[ede.InfoCode],
+		)
+	}
+}
+
+func TestReadyOffline(t *testing.T) {
+	netboxdns := NetboxDNS{
+		Next:  test.ErrorHandler(),
+		zones: []string{"."},
+		backend: &netbox.APIRequestClient{
+			Client: &http.Client{
+				Timeout: defaultHTTPClientTimeout,
+			},
+			NetboxURL: &url.URL{
+				Scheme: "http",
+				Host:   "localhost:9876",
+				Path:   testInstanceUrlPath,
+			},
+			Token: testInstanceToken,
+		},
+	}
+	tc := test.Case{
+		Qname: exampledotcomName, Qtype: dns.TypeA,
+	}
+	msg := tc.Msg()
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	netboxdns.ServeDNS(context.Background(), rec, msg)
+	if netboxdns.Ready() {
+		t.Error("expected plugin not to be ready")
+	}
+	if !netboxdns.LastContact().IsZero() {
+		t.Errorf("expected no last contact, got %s", netboxdns.LastContact())
+	}
+}
+
+func TestReady(t *testing.T) {
+	tc := test.Case{
+		Qname: exampledotcomName, Qtype: dns.TypeA,
+	}
+	msg := tc.Msg()
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	_, err := netboxdnsPlugin.ServeDNS(context.Background(), rec, msg)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !netboxdnsPlugin.Ready() {
+		t.Error("expected plugin to be ready")
+	}
+	if netboxdnsPlugin.LastContact().IsZero() {
+		t.Error("expected last contact to be set")
+	}
+}
+
+func TestTraceOffline(t *testing.T) {
+	netboxdns := NetboxDNS{
+		Next:  test.ErrorHandler(),
+		zones: []string{"."},
+		backend: &netbox.APIRequestClient{
+			Client: &http.Client{
+				Timeout: defaultHTTPClientTimeout,
+			},
+			NetboxURL: &url.URL{
+				Scheme: "http",
+				Host:   "localhost:9876",
+				Path:   testInstanceUrlPath,
+			},
+			Token: testInstanceToken,
+		},
+	}
+	tracer := mocktracer.New()
+	root := tracer.StartSpan("servedns")
+	ctx := ot.ContextWithSpan(context.Background(), root)
+	tc := test.Case{
+		Qname: exampledotcomName, Qtype: dns.TypeA,
+	}
+	msg := tc.Msg()
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	netboxdns.ServeDNS(ctx, rec, msg)
+	root.Finish()
+
+	finished := make(map[string]*mocktracer.MockSpan)
+	for _, span := range tracer.FinishedSpans() {
+		finished[span.OperationName] = span
+	}
+	for _, operationName := range []string{
+		"netboxdns.lookup",
+		"netboxdns.matchZone",
+		"netbox.GetZones",
+		"netbox.get zones",
+	} {
+		if _, ok := finished[operationName]; !ok {
+			t.Errorf("expected span %q, got none", operationName)
+		}
+	}
+	if span, ok := finished["netbox.get zones"]; ok {
+		if span.Tag("error") != true {
+			t.Error("expected api request span to be tagged with error")
+		}
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	canceled := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			canceled <- struct{}{}
+		},
+	))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	netboxdns := NetboxDNS{
+		Next:         test.ErrorHandler(),
+		zones:        []string{"."},
+		queryTimeout: time.Millisecond * 100,
+		backend: &netbox.APIRequestClient{
+			Client: &http.Client{
+				Timeout: time.Second * 30,
+			},
+			NetboxURL: serverURL.JoinPath(testInstanceUrlPath),
+			Token:     testInstanceToken,
+		},
+	}
+	tc := test.Case{
+		Qname: exampledotcomName, Qtype: dns.TypeA,
+	}
+	msg := tc.Msg()
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	start := time.Now()
+	_, err := netboxdns.ServeDNS(context.Background(), rec, msg)
+	if err == nil {
+		t.Error("expected timeout error, got none")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second*5 {
+		t.Errorf("expected lookup to be canceled, took %s", elapsed)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second * 5):
+		t.Error("expected request to netbox to be canceled")
+	}
+}
+
+func TestZoneIndexMatch(t *testing.T) {
+	index := newZoneIndex([]netbox.Zone{
+		{ID: 1, Name: "example.com"},
+		{ID: 2, Name: "sub.example.com"},
+		{ID: 3, Name: "10.in-addr.arpa"},
+	})
+	tests := []struct {
+		qname  string
+		wantID int
+	}{
+		{"example.com.", 1},
+		{"www.example.com.", 1},
+		{"sub.example.com.", 2},
+		{"a.b.SUB.example.com", 2},
+		{"notsub.example.com.", 1},
+		{"1.0.0.10.in-addr.arpa.", 3},
+		{"example.net.", 0},
+		{"com.", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.qname, func(t *testing.T) {
+			zone := index.match(tt.qname)
+			switch {
+			case zone == nil && tt.wantID != 0:
+				t.Errorf("expected zone %d, got none", tt.wantID)
+			case zone != nil && zone.ID != tt.wantID:
+				t.Errorf("expected zone %d, got %d", tt.wantID, zone.ID)
+			}
+		})
+	}
+}
+
+func TestZoneIndexDuplicateNames(t *testing.T) {
+	index := newZoneIndex([]netbox.Zone{
+		{ID: 1, Name: "example.com", View: netbox.View{Name: "internal"}},
+		{ID: 2, Name: "Example.com.", View: netbox.View{Name: "external"}},
+	})
+	zone := index.match("www.example.com.")
+	if zone == nil || zone.ID != 1 {
+		t.Errorf("expected the first zone with the name, got %+v", zone)
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	backend, err := netbox.NewFileBackend(".testing/export.json")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	netboxdns := NetboxDNS{
+		Next:    test.ErrorHandler(),
+		zones:   []string{"."},
+		backend: backend,
+	}
+	tests := []test.Case{
+		{
+			Qname: "www.example.com.", Qtype: dns.TypeA,
+			Answer: []dns.RR{
+				test.A("dns01.example.com. 3600 IN A 10.0.0.10"),
+				test.CNAME("www.example.com. 3600 IN CNAME dns01.example.com."),
+			},
+		},
+		{
+			Qname: "example.com.", Qtype: dns.TypeNS,
+			Answer: []dns.RR{
+				test.NS("example.com. 3600 IN NS dns01.example.com."),
+			},
+			Extra: []dns.RR{
+				test.A("dns01.example.com. 3600 IN A 10.0.0.10"),
+			},
+		},
+		{
+			Qname: "missing.example.com.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Qname, func(t *testing.T) {
+			rec := dnstest.NewRecorder(&test.ResponseWriter{})
+			_, err := netboxdns.ServeDNS(context.Background(), rec, tc.Msg())
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if err := test.SortAndCheck(rec.Msg, tc); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	if !netboxdns.Ready() {
+		t.Error("expected plugin to be ready")
+	}
+}
+
+func TestInstanceMatch(t *testing.T) {
+	corp := &NetboxDNS{zones: []string{"corp.example."}}
+	lab := &NetboxDNS{zones: []string{"lab.corp.example.", "lab.example."}}
+	netboxdns := &NetboxDNS{
+		zones:     []string{"example.com."},
+		instances: []*NetboxDNS{corp, lab},
+	}
+	tests := []struct {
+		qname string
+		want  *NetboxDNS
+	}{
+		{"www.example.com.", netboxdns},
+		{"corp.example.", corp},
+		{"www.corp.example.", corp},
+		{"www.lab.corp.example.", lab},
+		{"lab.example.", lab},
+		{"example.net.", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.qname, func(t *testing.T) {
+			if got := netboxdns.match(tt.qname); got != tt.want {
+				t.Errorf("expected instance %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	zone := &netbox.Zone{
+		ID:         1,
+		Name:       "example.com",
+		SOAMinimum: 3600,
+		SOATTL:     86400,
+		View:       netbox.View{Name: "default"},
+	}
+	otherView := &netbox.Zone{
+		ID:         2,
+		Name:       "example.com",
+		SOAMinimum: 3600,
+		SOATTL:     86400,
+		View:       netbox.View{Name: "internal"},
+	}
+	cache := newNegativeCache(2)
+	cache.add(zone, "noop.example.com", dns.TypeA)
+	if !cache.contains(zone, "NOOP.example.com", dns.TypeA) {
+		t.Error("expected cached name to be found")
+	}
+	if cache.contains(zone, "noop.example.com", dns.TypeAAAA) {
+		t.Error("expected other qtype not to be found")
+	}
+	if cache.contains(otherView, "noop.example.com", dns.TypeA) {
+		t.Error("expected other view not to be found")
+	}
+
+	cache.add(zone, "noop2.example.com", dns.TypeA)
+	cache.add(zone, "noop3.example.com", dns.TypeA)
+	if len(cache.entries) > 2 {
+		t.Errorf("expected at most 2 entries, got %d", len(cache.entries))
+	}
+
+	cache.purge()
+	if cache.contains(zone, "noop3.example.com", dns.TypeA) {
+		t.Error("expected purged name not to be found")
+	}
+
+	noNegativeTTL := &netbox.Zone{ID: 3, Name: "example.net"}
+	cache.add(noNegativeTTL, "noop.example.net", dns.TypeA)
+	if cache.contains(noNegativeTTL, "noop.example.net", dns.TypeA) {
+		t.Error("expected name from zone without negative ttl not to be cached")
+	}
+}
+
+func TestSkipInvalidRecords(t *testing.T) {
+	export := netbox.Export{
+		Zones: []netbox.Zone{{ID: 1, Name: "example.net", DefaultTTL: 3600}},
+		Records: []netbox.Record{
+			{ID: 1, Zone: netbox.Zone{ID: 1}, Type: "A", Name: "www", Value: "10.0.0.1"},
+			{ID: 2, Zone: netbox.Zone{ID: 1}, Type: "A", Name: "www", Value: "10.0.0"},
+		},
+	}
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := netbox.NewFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := test.Case{
+		Qname: "www.example.net.", Qtype: dns.TypeA, Do: true,
+		Answer: []dns.RR{
+			test.A("www.example.net. 3600 IN A 10.0.0.1"),
+		},
+	}
+	tests := []struct {
+		name    string
+		mode    invalidRecordsMode
+		wantEDE string
+	}{
+		{"skip", invalidRecordsSkip, ""},
+		{
+			"skip with ede",
+			invalidRecordsSkipEDE,
+			"netbox record 2 has an invalid value and was skipped",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			netboxdns := NetboxDNS{
+				Next:           test.ErrorHandler(),
+				zones:          []string{"."},
+				backend:        backend,
+				invalidRecords: tt.mode,
+				invalidLog:     newInvalidRecordLog(invalidRecordLogInterval),
+			}
+			rec := dnstest.NewRecorder(&test.ResponseWriter{})
+			_, err := netboxdns.ServeDNS(context.Background(), rec, tc.Msg())
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			// the OPT record is in the additional section, so only the answer
+			// is checked
+			if err := test.Section(tc, test.Answer, rec.Msg.Answer); err != nil {
+				t.Error(err)
+			}
+			var ede *dns.EDNS0_EDE
+			if opt := rec.Msg.IsEdns0(); opt != nil {
+				for _, option := range opt.Option {
+					if e, ok := option.(*dns.EDNS0_EDE); ok {
+						ede = e
+					}
+				}
+			}
+			switch {
+			case tt.wantEDE == "" && ede != nil:
+				t.Errorf("expected no extended dns error, got %q", ede.ExtraText)
+			case tt.wantEDE != "" && ede == nil:
+				t.Error("expected extended dns error, got none")
+			case ede != nil && ede.ExtraText != tt.wantEDE:
+				t.Errorf(
+					"expected extended dns error %q, got %q",
+					tt.wantEDE,
+					ede.ExtraText,
+				)
+			}
+		})
+	}
+
+	// without skip_invalid, the invalid record fails the response
+	netboxdns := NetboxDNS{
+		Next:    test.ErrorHandler(),
+		zones:   []string{"."},
+		backend: backend,
+	}
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := netboxdns.ServeDNS(context.Background(), rec, tc.Msg()); err == nil {
+		t.Error("expected an error for the invalid record, got none")
+	}
+	if rec.Msg == nil || rec.Msg.Rcode != dns.RcodeServerFailure {
+		t.Errorf("expected SERVFAIL response, got %v", rec.Msg)
+	}
+}
+
+func TestSkippedRecordsError(t *testing.T) {
+	ede := skippedRecordsError(skippedRecords{1, 2, 3, 4, 5, 6, 7})
+	want := "netbox records 1, 2, 3, 4, 5 and 2 more have invalid values and were skipped"
+	if ede.ExtraText != want {
+		t.Errorf("expected %q, got %q", want, ede.ExtraText)
+	}
+	if ede.InfoCode != dns.ExtendedErrorCodeInvalidData {
+		t.Errorf(
+			"expected extended error %q, got %q",
+			dns.ExtendedErrorCodeToString[dns.ExtendedErrorCodeInvalidData],
+			dns.ExtendedErrorCodeToString[ede.InfoCode],
+		)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	zone := netbox.Zone{ID: 1, Name: "example.net", DefaultTTL: 3600}
+	var records []netbox.Record
+	addRecord := func(name string, rrtype string, value string) {
+		records = append(records, netbox.Record{
+			ID:    len(records) + 1,
+			Zone:  netbox.Zone{ID: zone.ID},
+			Type:  rrtype,
+			Name:  name,
+			Value: value,
+		})
+	}
+	// 60 addresses do not fit in a 512 byte response
+	for k := range 60 {
+		addRecord("big", "A", fmt.Sprintf("10.0.0.%d", k+1))
+	}
+	// the exchangers fit, but not together with their addresses
+	for k := range 8 {
+		exchanger := fmt.Sprintf("mx%d", k)
+		addRecord("@", "MX", fmt.Sprintf("10 %s.example.net.", exchanger))
+		for l := range 3 {
+			addRecord(exchanger, "A", fmt.Sprintf("10.0.%d.%d", k+1, l+1))
+		}
+	}
+	data, err := json.Marshal(netbox.Export{
+		Zones:   []netbox.Zone{zone},
+		Records: records,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := netbox.NewFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	netboxdns := NetboxDNS{
+		Next:    test.ErrorHandler(),
+		zones:   []string{"."},
+		backend: backend,
+	}
+	query := func(qname string, qtype uint16) *dns.Msg {
+		t.Helper()
+		msg := new(dns.Msg)
+		msg.SetQuestion(qname, qtype)
+		msg.SetEdns0(512, false)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := netboxdns.ServeDNS(context.Background(), rec, msg); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if rec.Msg.Len() > 512 {
+			t.Errorf("expected response to fit in 512 bytes, got %d", rec.Msg.Len())
+		}
+		return rec.Msg
+	}
+
+	// additional records are dropped before the answer, and TC is set as
+	// records were left out
+	response := query("example.net.", dns.TypeMX)
+	if !response.Truncated {
+		t.Error("expected TC to be set")
+	}
+	if len(response.Answer) != 8 {
+		t.Errorf("expected 8 MX records, got %d", len(response.Answer))
+	}
+	if extra := filterRRByType(response.Extra, dns.TypeA); len(extra) >= 24 {
+		t.Errorf("expected additional records to be dropped, got %d", len(extra))
+	}
+
+	// an answer that does not fit is truncated and TC is set
+	response = query("big.example.net.", dns.TypeA)
+	if !response.Truncated {
+		t.Error("expected TC to be set")
+	}
+	if len(response.Answer) >= 60 {
+		t.Errorf("expected answer to be truncated, got %d records", len(response.Answer))
+	}
+}
+
+func TestZoneCacheMetrics(t *testing.T) {
+	backend, err := netbox.NewFileBackend(".testing/export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, []byte(`{"zones": [], "records": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	empty, err := netbox.NewFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := newZoneCache(time.Minute, []string{"example.com."})
+	second := newZoneCache(time.Minute, []string{"example.net.", "example.org."})
+	if _, err := first.refresh(context.Background(), backend); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.refresh(context.Background(), empty); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(zoneCacheSize.WithLabelValues("example.com.")); got == 0 {
+		t.Error("expected the zones of the first instance to be reported")
+	}
+	got := testutil.ToFloat64(
+		zoneCacheSize.WithLabelValues("example.net.,example.org."),
+	)
+	if got != 0 {
+		t.Errorf("expected no zones for the second instance, got %v", got)
+	}
+}
@@ -1,14 +1,19 @@
 package netboxdns
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/tls"
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
 )
 
 type tokenFuncMap map[string]func(*caddy.Controller, *NetboxDNS) error
@@ -17,44 +22,98 @@ var tokenFuncs tokenFuncMap
 
 func init() {
 	tokenFuncs = tokenFuncMap{
-		"fallthrough": parseFallthrough,
-		"timeout":     parseTimeout,
-		"tls":         parseTLS,
-		"token":       parseToken,
-		"url":         parseUrl,
+		"circuit_breaker": parseCircuitBreaker,
+		"fallthrough":     parseFallthrough,
+		"file":            parseFile,
+		"negative_cache":  parseNegativeCache,
+		"query_timeout":   parseQueryTimeout,
+		"retry":           parseRetry,
+		"skip_invalid":    parseSkipInvalid,
+		"startup_check":   parseStartupCheck,
+		"timeout":         parseTimeout,
+		"tls":             parseTLS,
+		"transport":       parseTransport,
+		"token":           parseToken,
+		"token_env":       parseTokenEnv,
+		"token_file":      parseTokenFile,
+		"token_scheme":    parseTokenScheme,
+		"url":             parseUrl,
+		"zone_cache":      parseZoneCache,
 	}
 }
 
-// Parse netboxdns configuration
+// Parse netboxdns configuration. The first netboxdns directive in the server
+// block configures netboxdns; any further directives are parsed into their own
+// instances, each serving its own zones.
 func Parse(controller *caddy.Controller, netboxdns *NetboxDNS) error {
 	instances := 0
 	for controller.Next() {
+		instance := netboxdns
 		if instances > 0 {
-			return plugin.ErrOnce
+			instance = NewNetboxDNS()
+			netboxdns.instances = append(netboxdns.instances, instance)
 		}
 		instances++
-		parseZones(controller, netboxdns)
-		if err := parseConfigTokens(controller, netboxdns); err != nil {
+		parseZones(controller, instance)
+		if err := parseConfigTokens(controller, instance); err != nil {
 			return err
 		}
+		if err := parseInstance(controller, instance); err != nil {
+			return err
+		}
+	}
+	return parseValidateZones(controller, netboxdns)
+}
+
+// parseInstance validates the configuration of a single netboxdns directive and
+// prepares its request client
+func parseInstance(controller *caddy.Controller, netboxdns *NetboxDNS) error {
+	// wired here rather than on startup, before anything refreshes the zone
+	// cache in the background
+	if netboxdns.zoneCache != nil && netboxdns.negativeCache != nil {
+		netboxdns.zoneCache.onRefresh = netboxdns.negativeCache.purge
+	}
+
+	if _, ok := netboxdns.backend.(*netbox.FileBackend); ok {
+		return parseValidateFile(controller, netboxdns)
 	}
 
 	if err := parseValidate(controller, netboxdns); err != nil {
 		return err
 	}
 
-	fullPluginURL := netboxdns.requestClient.NetboxURL.JoinPath(
-		"api",
-		"plugins",
-		"netbox-dns",
-	)
-	netboxdns.requestClient.NetboxURL = fullPluginURL
+	for _, endpoint := range netboxdns.requestClient.Endpoints {
+		endpoint.URL = endpoint.URL.JoinPath("api", "plugins", "netbox-dns")
+	}
+	netboxdns.requestClient.NetboxURL = netboxdns.requestClient.Endpoints[0].URL
 
 	netboxdns.requestClient.UserAgent = fmt.Sprintf(
 		"coredns plugin %s",
 		pluginName,
 	)
 
+	if err := parseStartupChecks(controller, netboxdns); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseValidateZones ensures that no zone is served by more than one instance,
+// as queries are dispatched by zone
+func parseValidateZones(controller *caddy.Controller, netboxdns *NetboxDNS) error {
+	seen := make(map[string]*NetboxDNS)
+	for _, instance := range netboxdns.all() {
+		for _, zone := range instance.zones {
+			if owner, ok := seen[zone]; ok && owner != instance {
+				return controller.Errf(
+					"zone %q is served by more than one netboxdns instance",
+					zone,
+				)
+			}
+			seen[zone] = instance
+		}
+	}
 	return nil
 }
 
@@ -73,7 +132,7 @@ func parseConfigTokens(controller *caddy.Controller, netboxdns *NetboxDNS) error
 		tokenName := controller.Val()
 		tokenFunc, ok := tokenFuncs[tokenName]
 		if !ok {
-			return unknownToken(controller, tokenName)
+			return unknownToken(controller, tokenName, tokenFuncs)
 		}
 		if err := tokenFunc(controller, netboxdns); err != nil {
 			return err
@@ -82,15 +141,19 @@ func parseConfigTokens(controller *caddy.Controller, netboxdns *NetboxDNS) error
 	return nil
 }
 
-func unknownToken(controller *caddy.Controller, unknownToken string) error {
+func unknownToken(
+	controller *caddy.Controller,
+	unknownToken string,
+	funcs tokenFuncMap,
+) error {
 	expectedTokenString := ""
 	i := 0
-	for tokenName := range tokenFuncs {
+	for tokenName := range funcs {
 		expectedTokenString += fmt.Sprintf("%q", tokenName)
-		if i+1 < len(tokenFuncs) {
+		if i+1 < len(funcs) {
 			expectedTokenString += ", "
 		}
-		if i == len(tokenFuncs)-2 {
+		if i == len(funcs)-2 {
 			expectedTokenString += "or "
 		}
 		i++
@@ -102,6 +165,39 @@ func unknownToken(controller *caddy.Controller, unknownToken string) error {
 	)
 }
 
+func parseCircuitBreaker(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	args := controller.RemainingArgs()
+	if len(args) == 0 || len(args) > 2 {
+		return controller.ArgErr()
+	}
+	threshold, err := strconv.Atoi(args[0])
+	if err != nil || threshold <= 0 {
+		return controller.Errf(
+			`"circuit_breaker" failures must be a positive integer: %q`,
+			args[0],
+		)
+	}
+	breaker := &netbox.CircuitBreaker{
+		Threshold: threshold,
+		Cooldown:  netbox.DefaultBreakerCooldown,
+	}
+	if len(args) == 2 {
+		cooldown, err := time.ParseDuration(args[1])
+		if err != nil {
+			return controller.Errf(
+				`there was an error parsing "circuit_breaker" cooldown: %q`,
+				err.Error(),
+			)
+		}
+		breaker.Cooldown = cooldown
+	}
+	netboxdns.requestClient.Breaker = breaker
+	return nil
+}
+
 func parseFallthrough(
 	controller *caddy.Controller,
 	netboxdns *NetboxDNS,
@@ -110,6 +206,92 @@ func parseFallthrough(
 	return nil
 }
 
+func parseFile(controller *caddy.Controller, netboxdns *NetboxDNS) error {
+	if !controller.NextArg() {
+		return controller.Err(`no value for "file" provided`)
+	}
+	backend, err := netbox.NewFileBackend(controller.Val())
+	if err != nil {
+		return controller.Errf(
+			`there was an error reading "file": %q`,
+			err.Error(),
+		)
+	}
+	netboxdns.backend = backend
+	return nil
+}
+
+func parseRetry(controller *caddy.Controller, netboxdns *NetboxDNS) error {
+	args := controller.RemainingArgs()
+	if len(args) == 0 || len(args) > 3 {
+		return controller.ArgErr()
+	}
+	attempts, err := strconv.Atoi(args[0])
+	if err != nil || attempts < 0 {
+		return controller.Errf(
+			`"retry" attempts must be a non-negative integer: %q`,
+			args[0],
+		)
+	}
+	policy := netbox.RetryPolicy{
+		Attempts:   attempts,
+		Backoff:    netbox.DefaultRetryBackoff,
+		MaxBackoff: netbox.DefaultRetryMaxBackoff,
+	}
+	durations := []*time.Duration{&policy.Backoff, &policy.MaxBackoff}
+	for i, arg := range args[1:] {
+		duration, err := time.ParseDuration(arg)
+		if err != nil {
+			return controller.Errf(
+				`there was an error parsing "retry" backoff: %q`,
+				err.Error(),
+			)
+		}
+		*durations[i] = duration
+	}
+	netboxdns.requestClient.Retry = policy
+	return nil
+}
+
+func parseSkipInvalid(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	args := controller.RemainingArgs()
+	switch {
+	case len(args) == 0:
+		netboxdns.invalidRecords = invalidRecordsSkip
+	case len(args) == 1 && args[0] == "ede":
+		netboxdns.invalidRecords = invalidRecordsSkipEDE
+	default:
+		return controller.Errf(
+			`unexpected "skip_invalid" arguments %q; expected none or "ede"`,
+			args,
+		)
+	}
+	netboxdns.invalidLog = newInvalidRecordLog(invalidRecordLogInterval)
+	return nil
+}
+
+func parseStartupCheck(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	args := controller.RemainingArgs()
+	switch {
+	case len(args) == 0:
+		netboxdns.startupCheck = startupCheckFail
+	case len(args) == 1 && args[0] == "warn":
+		netboxdns.startupCheck = startupCheckWarn
+	default:
+		return controller.Errf(
+			`unexpected "startup_check" arguments %q; expected none or "warn"`,
+			args,
+		)
+	}
+	return nil
+}
+
 func parseTimeout(controller *caddy.Controller, netboxdns *NetboxDNS) error {
 	if !controller.NextArg() {
 		return controller.Err(`no value for "timeout" provided`)
@@ -125,15 +307,67 @@ func parseTimeout(controller *caddy.Controller, netboxdns *NetboxDNS) error {
 	return nil
 }
 
+func parseNegativeCache(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	capacity := defaultNegativeCacheCapacity
+	args := controller.RemainingArgs()
+	switch len(args) {
+	case 0:
+	case 1:
+		value, err := strconv.Atoi(args[0])
+		if err != nil || value <= 0 {
+			return controller.Errf(
+				`"negative_cache" capacity must be a positive integer: %q`,
+				args[0],
+			)
+		}
+		capacity = value
+	default:
+		return controller.ArgErr()
+	}
+	netboxdns.negativeCache = newNegativeCache(capacity)
+	return nil
+}
+
+func parseQueryTimeout(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	if !controller.NextArg() {
+		return controller.Err(`no value for "query_timeout" provided`)
+	}
+	duration, err := time.ParseDuration(controller.Val())
+	if err != nil {
+		return controller.Errf(
+			`there was an error parsing "query_timeout": %q`,
+			err.Error(),
+		)
+	}
+	if duration <= 0 {
+		return controller.Errf(
+			`"query_timeout" must be a positive duration: %q`,
+			controller.Val(),
+		)
+	}
+	netboxdns.queryTimeout = duration
+	return nil
+}
+
 func parseTLS(controller *caddy.Controller, netboxdns *NetboxDNS) error {
 	args := controller.RemainingArgs()
 	tlsConfig, err := tls.NewTLSConfigFromArgs(args...)
 	if err != nil {
 		return err
 	}
-	netboxdns.requestClient.Client.Transport = &http.Transport{
-		TLSClientConfig: tlsConfig,
+	transport := netboxdns.transport()
+	if transport.TLSClientConfig != nil {
+		// keep settings from the "transport" block
+		tlsConfig.ServerName = transport.TLSClientConfig.ServerName
+		tlsConfig.InsecureSkipVerify = transport.TLSClientConfig.InsecureSkipVerify
 	}
+	transport.TLSClientConfig = tlsConfig
 	return nil
 }
 
@@ -145,25 +379,173 @@ func parseToken(controller *caddy.Controller, netboxdns *NetboxDNS) error {
 	return nil
 }
 
-func parseUrl(controller *caddy.Controller, netboxdns *NetboxDNS) error {
+func parseTokenEnv(controller *caddy.Controller, netboxdns *NetboxDNS) error {
+	if !controller.NextArg() {
+		return controller.Err(`no value for "token_env" provided`)
+	}
+	name := controller.Val()
+	token := strings.TrimSpace(os.Getenv(name))
+	if token == "" {
+		return controller.Errf(
+			`environment variable %q for "token_env" is not set or empty`,
+			name,
+		)
+	}
+	netboxdns.requestClient.Token = token
+	return nil
+}
+
+func parseTokenFile(controller *caddy.Controller, netboxdns *NetboxDNS) error {
 	if !controller.NextArg() {
+		return controller.Err(`no value for "token_file" provided`)
+	}
+	fileToken, err := netbox.NewFileToken(controller.Val())
+	if err != nil {
+		return controller.Errf(
+			`there was an error reading "token_file": %q`,
+			err.Error(),
+		)
+	}
+	netboxdns.requestClient.TokenSource = fileToken
+	return nil
+}
+
+func parseTokenScheme(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	if !controller.NextArg() {
+		return controller.Err(`no value for "token_scheme" provided`)
+	}
+	scheme, ok := netbox.StringToTokenScheme[strings.ToLower(controller.Val())]
+	if !ok {
+		return controller.Errf(
+			`unknown "token_scheme" %q; expected "auto", "token", or "bearer"`,
+			controller.Val(),
+		)
+	}
+	netboxdns.requestClient.TokenScheme = scheme
+	return nil
+}
+
+func parseUrl(controller *caddy.Controller, netboxdns *NetboxDNS) error {
+	args := controller.RemainingArgs()
+	if len(args) == 0 {
 		return controller.Err(`no value for "url" provided`)
 	}
-	netboxUrl, err := url.Parse(controller.Val())
+	for _, arg := range args {
+		netboxUrl, err := url.Parse(arg)
+		if err != nil {
+			return controller.Errf(
+				`there was an error parsing "url": %q`,
+				err.Error(),
+			)
+		}
+		netboxdns.requestClient.Endpoints = append(
+			netboxdns.requestClient.Endpoints,
+			netbox.NewEndpoint(netboxUrl),
+		)
+	}
+	netboxdns.requestClient.NetboxURL = netboxdns.requestClient.Endpoints[0].URL
+	return nil
+}
+
+func parseZoneCache(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	if !controller.NextArg() {
+		return controller.Err(`no value for "zone_cache" provided`)
+	}
+	duration, err := time.ParseDuration(controller.Val())
 	if err != nil {
 		return controller.Errf(
-			`there was an error parsing "url": %q`,
+			`there was an error parsing "zone_cache": %q`,
 			err.Error(),
 		)
 	}
-	netboxdns.requestClient.NetboxURL = netboxUrl
+	if duration <= 0 {
+		netboxdns.zoneCache = nil
+		return nil
+	}
+	netboxdns.zoneCache = newZoneCache(duration, netboxdns.zones)
 	return nil
 }
 
+// startupContext returns a context for requests made to Netbox during setup,
+// bounded by the configured timeout
+func startupContext(
+	netboxdns *NetboxDNS,
+) (context.Context, context.CancelFunc) {
+	timeout := netboxdns.requestClient.Client.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPClientTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// validateTokenScheme checks that Netbox accepts the token with the configured
+// scheme at every endpoint, so that a v2 token configured with the "token"
+// scheme, or the reverse, fails setup instead of every query
+func validateTokenScheme(netboxdns *NetboxDNS) error {
+	ctx, cancel := startupContext(netboxdns)
+	defer cancel()
+	err := netbox.ValidateTokenScheme(ctx, netboxdns.requestClient)
+	var schemeErr *netbox.TokenSchemeError
+	if errors.As(err, &schemeErr) {
+		return fmt.Errorf(
+			`%w; set "token_scheme %s"`,
+			err,
+			strings.ToLower(schemeErr.Accepted.String()),
+		)
+	}
+	return err
+}
+
+// parseStartupChecks verifies that Netbox accepts the token with the configured
+// scheme, failing setup if Netbox rejects it. If "startup_check" is enabled, it
+// also verifies that Netbox is reachable at the configured URL, runs a
+// supported netbox-plugin-dns version, and that the token has the permissions
+// required
+func parseStartupChecks(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	err := validateTokenScheme(netboxdns)
+	var schemeErr *netbox.TokenSchemeError
+	if errors.As(err, &schemeErr) || errors.Is(err, netbox.ErrTokenRejected) {
+		return controller.Errf("netbox token check failed: %v", err)
+	}
+	if netboxdns.startupCheck == startupCheckNone {
+		if err != nil {
+			logger.Warningf("netbox token check skipped: %v", err)
+		}
+		return nil
+	}
+	if err == nil {
+		ctx, cancel := startupContext(netboxdns)
+		defer cancel()
+		err = netbox.Check(ctx, netboxdns.requestClient)
+	}
+	if err == nil {
+		return nil
+	}
+	if netboxdns.startupCheck == startupCheckWarn {
+		logger.Warningf("netbox startup check failed: %v", err)
+		return nil
+	}
+	return controller.Errf("netbox startup check failed: %v", err)
+}
+
 func parseValidate(controller *caddy.Controller, netboxdns *NetboxDNS) error {
-	tokenEmpty := netboxdns.requestClient.Token == ""
-	urlEmpty := netboxdns.requestClient.NetboxURL == nil ||
-		netboxdns.requestClient.NetboxURL.Host == ""
+	tokenEmpty := netboxdns.requestClient.Token == "" &&
+		netboxdns.requestClient.TokenSource == nil
+	urlEmpty := len(netboxdns.requestClient.Endpoints) == 0
+	for _, endpoint := range netboxdns.requestClient.Endpoints {
+		if endpoint.URL.Host == "" {
+			urlEmpty = true
+		}
+	}
 	if tokenEmpty && urlEmpty {
 		return controller.Err(
 			`values are required for "token" and "url"`,
@@ -177,3 +559,14 @@ func parseValidate(controller *caddy.Controller, netboxdns *NetboxDNS) error {
 	}
 	return nil
 }
+
+// parseValidateFile ensures that an instance serving a "file" is not also
+// configured to connect to Netbox
+func parseValidateFile(controller *caddy.Controller, netboxdns *NetboxDNS) error {
+	tokenSet := netboxdns.requestClient.Token != "" ||
+		netboxdns.requestClient.TokenSource != nil
+	if tokenSet || len(netboxdns.requestClient.Endpoints) != 0 {
+		return controller.Err(`"file" cannot be combined with "token" or "url"`)
+	}
+	return nil
+}
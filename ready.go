@@ -0,0 +1,77 @@
+package netboxdns
+
+import (
+	"context"
+	"time"
+)
+
+const readyRetryInterval time.Duration = time.Second * 5
+
+// Ready implements the ready.Readiness interface. The plugin is ready once the
+// zone list has been successfully fetched from Netbox by every configured
+// instance, which also confirms that the configured tokens are valid.
+func (netboxdns *NetboxDNS) Ready() bool {
+	for _, instance := range netboxdns.all() {
+		if !instance.ready.Load() {
+			return false
+		}
+	}
+	return true
+}
+
+// LastContact returns the time Netbox last answered a request successfully. If
+// several instances are configured, the least recent contact is returned.
+func (netboxdns *NetboxDNS) LastContact() time.Time {
+	lastContact := netboxdns.backend.LastContact()
+	for _, instance := range netboxdns.instances {
+		if contact := instance.backend.LastContact(); contact.Before(lastContact) {
+			lastContact = contact
+		}
+	}
+	return lastContact
+}
+
+// OnStartup starts the initial zone fetch in the background, and the zone cache
+// refresh if it is enabled, for every configured instance
+func (netboxdns *NetboxDNS) OnStartup() error {
+	for _, instance := range netboxdns.all() {
+		instance.start()
+	}
+	return nil
+}
+
+// OnShutdown cancels the background zone fetches
+func (netboxdns *NetboxDNS) OnShutdown() error {
+	for _, instance := range netboxdns.all() {
+		if instance.cancel != nil {
+			instance.cancel()
+		}
+	}
+	return nil
+}
+
+func (netboxdns *NetboxDNS) start() {
+	var ctx context.Context
+	ctx, netboxdns.cancel = context.WithCancel(context.Background())
+	go netboxdns.waitReady(ctx)
+	if netboxdns.zoneCache != nil {
+		go netboxdns.zoneCache.run(ctx, netboxdns.backend)
+	}
+}
+
+func (netboxdns *NetboxDNS) waitReady(ctx context.Context) {
+	for !netboxdns.ready.Load() {
+		_, err := netboxdns.zoneIndex(ctx)
+		if err == nil {
+			netboxdns.ready.Store(true)
+			logger.Info("initial zone fetch from netbox succeeded")
+			return
+		}
+		logger.Warningf("could not fetch zones from netbox: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(readyRetryInterval):
+		}
+	}
+}
@@ -15,9 +15,13 @@ func setup(controller *caddy.Controller) error {
 	if err := Parse(controller, netboxdns); err != nil {
 		return err
 	}
+	controller.OnStartup(netboxdns.OnStartup)
+	controller.OnShutdown(netboxdns.OnShutdown)
 	dnsserver.GetConfig(controller).AddPlugin(
 		func(next plugin.Handler) plugin.Handler {
-			netboxdns.Next = next
+			for _, instance := range netboxdns.all() {
+				instance.Next = next
+			}
 			return netboxdns
 		},
 	)
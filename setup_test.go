@@ -1,9 +1,15 @@
 package netboxdns
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/coredns/caddy"
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
 )
 
 type SetupTest struct {
@@ -63,6 +69,135 @@ var setupTests []SetupTest = []SetupTest{
 		}`,
 		false,
 	},
+	{
+		"multiple netbox urls",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/ http://localhost:9998/
+		}`,
+		false,
+	},
+	{
+		"repeated netbox url",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			url http://localhost:9998/
+		}`,
+		false,
+	},
+	{
+		"multiple netbox urls with one invalid",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/ /noop
+		}`,
+		true,
+	},
+	{
+		"minimum configuration with token_env",
+		`netboxdns {
+			token_env NETBOXDNS_TEST_TOKEN
+			url http://localhost:9999/
+		}`,
+		false,
+	},
+	{
+		"unset token_env",
+		`netboxdns {
+			token_env NETBOXDNS_TEST_NOOP
+			url http://localhost:9999/
+		}`,
+		true,
+	},
+	{
+		"minimum configuration with token_file",
+		`netboxdns {
+			token_file .testing/token
+			url http://localhost:9999/
+		}`,
+		false,
+	},
+	{
+		"nonexistant token_file",
+		`netboxdns {
+			token_file noop.token
+			url http://localhost:9999/
+		}`,
+		true,
+	},
+	{
+		"minimum configuration with token_scheme",
+		`netboxdns {
+			token nbt_sometoken
+			url http://localhost:9999/
+			token_scheme bearer
+		}`,
+		false,
+	},
+	{
+		"unknown token_scheme",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			token_scheme basic
+		}`,
+		true,
+	},
+	{
+		"startup_check unreachable netbox",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9876/
+			startup_check
+		}`,
+		true,
+	},
+	{
+		"startup_check warn unreachable netbox",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9876/
+			startup_check warn
+		}`,
+		false,
+	},
+	{
+		"skip_invalid",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			skip_invalid
+		}`,
+		false,
+	},
+	{
+		"skip_invalid ede",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			skip_invalid ede
+		}`,
+		false,
+	},
+	{
+		"invalid skip_invalid argument",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			skip_invalid log
+		}`,
+		true,
+	},
+	{
+		"invalid startup_check argument",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			startup_check noop
+		}`,
+		true,
+	},
 	{
 		"invalid netbox url value",
 		`netboxdns {
@@ -72,7 +207,7 @@ var setupTests []SetupTest = []SetupTest{
 		true,
 	},
 	{
-		"multiple configurations",
+		"multiple configurations for the same zone",
 		`netboxdns {
 			token sometoken
 			url http://localhost:9999/
@@ -83,6 +218,30 @@ var setupTests []SetupTest = []SetupTest{
 		}`,
 		true,
 	},
+	{
+		"multiple configurations for different zones",
+		`netboxdns corp.example {
+			token sometoken
+			url http://localhost:9999/
+		}
+		netboxdns lab.example {
+			token_env NETBOXDNS_TEST_TOKEN
+			url http://localhost:9998/
+			fallthrough
+		}`,
+		false,
+	},
+	{
+		"multiple configurations with one invalid",
+		`netboxdns corp.example {
+			token sometoken
+			url http://localhost:9999/
+		}
+		netboxdns lab.example {
+			url http://localhost:9998/
+		}`,
+		true,
+	},
 	{
 		"configuration with responsible zone",
 		`netboxdns example.com {
@@ -91,6 +250,36 @@ var setupTests []SetupTest = []SetupTest{
 		}`,
 		false,
 	},
+	{
+		"configuration with file",
+		`netboxdns {
+			file .testing/export.json
+		}`,
+		false,
+	},
+	{
+		"no value for file specified",
+		`netboxdns {
+			file
+		}`,
+		true,
+	},
+	{
+		"missing file",
+		`netboxdns {
+			file .testing/noop.json
+		}`,
+		true,
+	},
+	{
+		"file with url",
+		`netboxdns {
+			file .testing/export.json
+			token sometoken
+			url http://localhost:9999/
+		}`,
+		true,
+	},
 	{
 		"no value for timeout specified",
 		`netboxdns {
@@ -118,6 +307,177 @@ var setupTests []SetupTest = []SetupTest{
 		}`,
 		true,
 	},
+	{
+		"no value for query_timeout specified",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			query_timeout
+		}`,
+		true,
+	},
+	{
+		"minimum configuration with query_timeout",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			query_timeout 2s
+		}`,
+		false,
+	},
+	{
+		"invalid query_timeout",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			query_timeout 2g
+		}`,
+		true,
+	},
+	{
+		"negative query_timeout",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			query_timeout -1s
+		}`,
+		true,
+	},
+	{
+		"zero query_timeout",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			query_timeout 0s
+		}`,
+		true,
+	},
+	{
+		"no value for zone_cache specified",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			zone_cache
+		}`,
+		true,
+	},
+	{
+		"minimum configuration with zone_cache",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			zone_cache 5m
+		}`,
+		false,
+	},
+	{
+		"minimum configuration with zone_cache disabled",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			zone_cache 0s
+		}`,
+		false,
+	},
+	{
+		"invalid zone_cache",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			zone_cache 5x
+		}`,
+		true,
+	},
+	{
+		"minimum configuration with negative_cache",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			negative_cache
+		}`,
+		false,
+	},
+	{
+		"minimum configuration with negative_cache capacity",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			negative_cache 500
+		}`,
+		false,
+	},
+	{
+		"invalid negative_cache capacity",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			negative_cache -1
+		}`,
+		true,
+	},
+	{
+		"too many negative_cache arguments",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			negative_cache 500 600
+		}`,
+		true,
+	},
+	{
+		"minimum configuration with retry",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			retry 3
+		}`,
+		false,
+	},
+	{
+		"minimum configuration with retry backoff",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			retry 3 200ms 5s
+		}`,
+		false,
+	},
+	{
+		"no value for retry specified",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			retry
+		}`,
+		true,
+	},
+	{
+		"invalid retry backoff",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			retry 3 fast
+		}`,
+		true,
+	},
+	{
+		"minimum configuration with circuit_breaker",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			circuit_breaker 5 1m
+		}`,
+		false,
+	},
+	{
+		"invalid circuit_breaker failures",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			circuit_breaker 0
+		}`,
+		true,
+	},
 	{
 		"minimum configuration fallthrough all zones",
 		`netboxdns {
@@ -181,9 +541,92 @@ var setupTests []SetupTest = []SetupTest{
 		}`,
 		false,
 	},
+	{
+		"minimum configuration with transport",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			transport {
+				proxy http://proxy.example.com:3128
+				server_name netbox.example.com
+				insecure_skip_verify
+				max_idle_conns 20
+				idle_timeout 30s
+				http2 off
+				compression on
+			}
+		}`,
+		false,
+	},
+	{
+		"transport after tls",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			tls .testing/tls/ca.pem
+			transport {
+				server_name netbox.example.com
+			}
+			timeout 10s
+		}`,
+		false,
+	},
+	{
+		"empty transport",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			transport {
+			}
+		}`,
+		false,
+	},
+	{
+		"transport without block",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			transport
+		}`,
+		true,
+	},
+	{
+		"unknown transport token",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			transport {
+				noop
+			}
+		}`,
+		true,
+	},
+	{
+		"invalid transport proxy",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			transport {
+				proxy noop
+			}
+		}`,
+		true,
+	},
+	{
+		"invalid transport http2",
+		`netboxdns {
+			token sometoken
+			url http://localhost:9999/
+			transport {
+				http2 maybe
+			}
+		}`,
+		true,
+	},
 }
 
 func TestSetup(t *testing.T) {
+	t.Setenv("NETBOXDNS_TEST_TOKEN", "sometoken")
 	for _, tt := range setupTests {
 		t.Run(tt.Name, func(t *testing.T) {
 			controller := caddy.NewTestController("dns", tt.Corefile)
@@ -197,3 +640,113 @@ func TestSetup(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTransport(t *testing.T) {
+	controller := caddy.NewTestController("dns", `netboxdns {
+		token sometoken
+		url http://localhost:9999/
+		transport {
+			server_name netbox.example.com
+			max_idle_conns 20
+			http2 off
+			compression off
+		}
+		tls .testing/tls/ca.pem
+	}`)
+	netboxdns := NewNetboxDNS()
+	if err := Parse(controller, netboxdns); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	transport, ok := netboxdns.requestClient.Client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatal("expected client to use an *http.Transport")
+	}
+	if transport.Proxy == nil {
+		t.Error("expected proxy from environment to be kept")
+	}
+	if transport.TLSClientConfig == nil ||
+		transport.TLSClientConfig.RootCAs == nil {
+		t.Error("expected tls configuration to be applied")
+	} else if transport.TLSClientConfig.ServerName != "netbox.example.com" {
+		t.Errorf(
+			"expected server name %q, got %q",
+			"netbox.example.com",
+			transport.TLSClientConfig.ServerName,
+		)
+	}
+	if transport.MaxIdleConns != 20 {
+		t.Errorf("expected 20 max idle conns, got %d", transport.MaxIdleConns)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Error("expected http2 to be disabled")
+	}
+	if !transport.DisableCompression {
+		t.Error("expected compression to be disabled")
+	}
+}
+
+func TestParseNegativeCache(t *testing.T) {
+	controller := caddy.NewTestController("dns", `netboxdns {
+		token sometoken
+		url http://localhost:9999/
+		negative_cache
+		zone_cache 1m
+	}`)
+	netboxdns := NewNetboxDNS()
+	if err := Parse(controller, netboxdns); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if netboxdns.zoneCache.onRefresh == nil {
+		t.Fatal("expected zone cache refresh to purge the negative cache")
+	}
+	netboxdns.negativeCache.entries[negativeCacheKey{qname: "a"}] = time.Now()
+	netboxdns.zoneCache.onRefresh()
+	if len(netboxdns.negativeCache.entries) != 0 {
+		t.Error("expected negative cache to be purged")
+	}
+}
+
+func TestParseTokenRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"detail": "Invalid token"}`))
+		},
+	))
+	defer server.Close()
+	controller := caddy.NewTestController("dns", fmt.Sprintf(`netboxdns {
+		token sometoken
+		url %s
+	}`, server.URL))
+	err := Parse(controller, NewNetboxDNS())
+	if err == nil ||
+		!strings.Contains(err.Error(), netbox.ErrTokenRejected.Error()) {
+		t.Errorf("expected %v, got %v", netbox.ErrTokenRejected, err)
+	}
+}
+
+func TestParseTransportHTTP2Reenabled(t *testing.T) {
+	controller := caddy.NewTestController("dns", `netboxdns {
+		token sometoken
+		url http://localhost:9999/
+		transport {
+			http2 off
+			http2 on
+		}
+	}`)
+	netboxdns := NewNetboxDNS()
+	if err := Parse(controller, netboxdns); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	transport, ok := netboxdns.requestClient.Client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatal("expected client to use an *http.Transport")
+	}
+	// the token check at setup lets the transport register its own h2
+	// handler, so only an empty TLSNextProto means http2 is disabled
+	disabled := transport.TLSNextProto != nil &&
+		transport.TLSNextProto["h2"] == nil
+	if !transport.ForceAttemptHTTP2 || disabled {
+		t.Error("expected the last http2 value to enable http2")
+	}
+}
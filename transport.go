@@ -0,0 +1,210 @@
+package netboxdns
+
+import (
+	cryptotls "crypto/tls"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/coredns/caddy"
+)
+
+var transportTokenFuncs tokenFuncMap
+
+func init() {
+	transportTokenFuncs = tokenFuncMap{
+		"compression":          parseTransportCompression,
+		"http2":                parseTransportHTTP2,
+		"idle_timeout":         parseTransportIdleTimeout,
+		"insecure_skip_verify": parseTransportInsecureSkipVerify,
+		"max_idle_conns":       parseTransportMaxIdleConns,
+		"proxy":                parseTransportProxy,
+		"server_name":          parseTransportServerName,
+	}
+}
+
+// transport returns the HTTP transport of the API client, replacing the
+// default transport with a copy that can be configured
+func (netboxdns *NetboxDNS) transport() *http.Transport {
+	client := netboxdns.requestClient.Client
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		return transport
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	client.Transport = transport
+	return transport
+}
+
+// tlsClientConfig returns the TLS configuration of the API client transport,
+// creating one if "tls" has not been specified
+func (netboxdns *NetboxDNS) tlsClientConfig() *cryptotls.Config {
+	transport := netboxdns.transport()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &cryptotls.Config{}
+	}
+	return transport.TLSClientConfig
+}
+
+func parseTransport(controller *caddy.Controller, netboxdns *NetboxDNS) error {
+	if !controller.NextArg() || controller.Val() != "{" {
+		return controller.Err(`expected "{" to open "transport" block`)
+	}
+	for controller.Next() {
+		tokenName := controller.Val()
+		if tokenName == "}" {
+			return nil
+		}
+		tokenFunc, ok := transportTokenFuncs[tokenName]
+		if !ok {
+			return unknownToken(controller, tokenName, transportTokenFuncs)
+		}
+		if err := tokenFunc(controller, netboxdns); err != nil {
+			return err
+		}
+	}
+	return controller.Err(`expected "}" to close "transport" block`)
+}
+
+// parseTransportToggle parses an optional "on" or "off" argument, where no
+// argument means "on"
+func parseTransportToggle(controller *caddy.Controller) (bool, error) {
+	args := controller.RemainingArgs()
+	switch {
+	case len(args) == 0:
+		return true, nil
+	case len(args) == 1 && args[0] == "on":
+		return true, nil
+	case len(args) == 1 && args[0] == "off":
+		return false, nil
+	}
+	return false, controller.Errf(
+		`unexpected %q arguments %q; expected "on" or "off"`,
+		controller.Val(),
+		args,
+	)
+}
+
+func parseTransportCompression(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	enabled, err := parseTransportToggle(controller)
+	if err != nil {
+		return err
+	}
+	netboxdns.transport().DisableCompression = !enabled
+	return nil
+}
+
+func parseTransportHTTP2(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	enabled, err := parseTransportToggle(controller)
+	if err != nil {
+		return err
+	}
+	transport := netboxdns.transport()
+	transport.ForceAttemptHTTP2 = enabled
+	if enabled {
+		// a nil map lets the transport configure HTTP/2 on first use
+		transport.TLSNextProto = nil
+	} else {
+		// a non-nil, empty map disables HTTP/2
+		transport.TLSNextProto = make(
+			map[string]func(string, *cryptotls.Conn) http.RoundTripper,
+		)
+	}
+	return nil
+}
+
+func parseTransportIdleTimeout(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	if !controller.NextArg() {
+		return controller.Err(`no value for "idle_timeout" provided`)
+	}
+	duration, err := time.ParseDuration(controller.Val())
+	if err != nil {
+		return controller.Errf(
+			`there was an error parsing "idle_timeout": %q`,
+			err.Error(),
+		)
+	}
+	netboxdns.transport().IdleConnTimeout = duration
+	return nil
+}
+
+func parseTransportInsecureSkipVerify(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	if controller.NextArg() {
+		return controller.ArgErr()
+	}
+	logger.Warning(
+		"server certificate verification is disabled for the netbox api",
+	)
+	netboxdns.tlsClientConfig().InsecureSkipVerify = true
+	return nil
+}
+
+func parseTransportMaxIdleConns(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	if !controller.NextArg() {
+		return controller.Err(`no value for "max_idle_conns" provided`)
+	}
+	value, err := strconv.Atoi(controller.Val())
+	if err != nil || value < 0 {
+		return controller.Errf(
+			`"max_idle_conns" must be a non-negative integer: %q`,
+			controller.Val(),
+		)
+	}
+	transport := netboxdns.transport()
+	transport.MaxIdleConns = value
+	transport.MaxIdleConnsPerHost = value
+	return nil
+}
+
+func parseTransportProxy(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	if !controller.NextArg() {
+		return controller.Err(`no value for "proxy" provided`)
+	}
+	transport := netboxdns.transport()
+	switch controller.Val() {
+	case "none":
+		transport.Proxy = nil
+		return nil
+	case "environment":
+		transport.Proxy = http.ProxyFromEnvironment
+		return nil
+	}
+	proxyUrl, err := url.Parse(controller.Val())
+	if err != nil || proxyUrl.Host == "" {
+		return controller.Errf(
+			`there was an error parsing "proxy": %q`,
+			controller.Val(),
+		)
+	}
+	transport.Proxy = http.ProxyURL(proxyUrl)
+	return nil
+}
+
+func parseTransportServerName(
+	controller *caddy.Controller,
+	netboxdns *NetboxDNS,
+) error {
+	if !controller.NextArg() {
+		return controller.Err(`no value for "server_name" provided`)
+	}
+	netboxdns.tlsClientConfig().ServerName = controller.Val()
+	return nil
+}
@@ -0,0 +1,129 @@
+package netboxdns
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/doubleu-labs/coredns-netbox-plugin-dns/internal/netbox"
+	"github.com/miekg/dns"
+)
+
+// zoneIndex maps lowercase zone names to zones so that the closest enclosing
+// zone of a name can be found by walking its labels
+type zoneIndex map[string]netbox.Zone
+
+// newZoneIndex indexes zones by name. If several zones have the same name, as
+// happens when it exists in more than one Netbox view, the first is kept.
+func newZoneIndex(zones []netbox.Zone) zoneIndex {
+	index := make(zoneIndex, len(zones))
+	for _, zone := range zones {
+		key := strings.ToLower(strings.TrimSuffix(zone.Name, "."))
+		if _, ok := index[key]; !ok {
+			index[key] = zone
+		}
+	}
+	return index
+}
+
+// match returns the longest zone that qname is equal to or a subdomain of, or
+// nil if there is none
+func (index zoneIndex) match(qname string) *netbox.Zone {
+	name := strings.ToLower(strings.TrimSuffix(qname, "."))
+	for _, offset := range dns.Split(name) {
+		if zone, ok := index[name[offset:]]; ok {
+			return &zone
+		}
+	}
+	return nil
+}
+
+// zoneCache holds the zone list fetched from Netbox for ttl so that it is not
+// fetched for every query
+type zoneCache struct {
+	ttl time.Duration
+	// label identifies the instance the cache belongs to in metrics by the
+	// zones it serves
+	label string
+	// onRefresh, if set, is called after the zone list is refreshed
+	onRefresh func()
+
+	mutex     sync.RWMutex
+	index     zoneIndex
+	refreshed time.Time
+}
+
+func newZoneCache(ttl time.Duration, zones []string) *zoneCache {
+	return &zoneCache{ttl: ttl, label: strings.Join(zones, ",")}
+}
+
+// get returns the cached zone index, fetching it from Netbox if it is missing
+// or has expired. If the fetch fails, an expired index is served instead.
+func (cache *zoneCache) get(
+	ctx context.Context,
+	backend netbox.Backend,
+) (zoneIndex, error) {
+	cache.mutex.RLock()
+	index, refreshed := cache.index, cache.refreshed
+	cache.mutex.RUnlock()
+	if index != nil && time.Since(refreshed) < cache.ttl {
+		return index, nil
+	}
+	fresh, err := cache.refresh(ctx, backend)
+	if err != nil {
+		if index != nil {
+			logger.Warningf(
+				"could not refresh zones from netbox, using zones from %s: %v",
+				refreshed.Format(time.RFC3339),
+				err,
+			)
+			return index, nil
+		}
+		return nil, err
+	}
+	return fresh, nil
+}
+
+func (cache *zoneCache) refresh(
+	ctx context.Context,
+	backend netbox.Backend,
+) (zoneIndex, error) {
+	zones, err := backend.Zones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	index := newZoneIndex(zones)
+	refreshed := time.Now()
+	cache.mutex.Lock()
+	cache.index = index
+	cache.refreshed = refreshed
+	cache.mutex.Unlock()
+	zoneCacheSize.WithLabelValues(cache.label).Set(float64(len(index)))
+	zoneCacheRefreshed.WithLabelValues(cache.label).Set(
+		float64(refreshed.Unix()),
+	)
+	if cache.onRefresh != nil {
+		cache.onRefresh()
+	}
+	return index, nil
+}
+
+// run refreshes the cache in the background every ttl until ctx is done
+func (cache *zoneCache) run(
+	ctx context.Context,
+	backend netbox.Backend,
+) {
+	ticker := time.NewTicker(cache.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := cache.refresh(ctx, backend); err != nil {
+				logger.Warningf("could not refresh zones from netbox: %v", err)
+			}
+		}
+	}
+}